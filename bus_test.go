@@ -0,0 +1,231 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestBus tests that every subscriber receives its filtered events, and that slow subscribers don't stall the engine.
+func TestBus(t *testing.T) {
+	params := gol.Params{Turns: 100, Threads: 8, ImageWidth: 64, ImageHeight: 64, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	bus := gol.NewBus()
+	all := bus.Subscribe(gol.SubscribeOptions{})
+	turns := bus.Subscribe(gol.SubscribeOptions{Filter: gol.Only(gol.TurnComplete{}, gol.FinalTurnComplete{})})
+	stalled := bus.Subscribe(gol.SubscribeOptions{})
+	dropping := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Drop, Buffer: 5})
+	leaving := bus.Subscribe(gol.SubscribeOptions{})
+	leaving.Unsubscribe()
+
+	golDone := make(chan bool, 1)
+	go func() {
+		gol.Run(params, events, make(chan rune, 10))
+		golDone <- true
+	}()
+	go bus.Forward(events)
+
+	// Nothing reads from stalled or dropping until the run has finished.
+	var received []gol.Event
+	done := make(chan bool)
+	go func() {
+		for event := range all.Events {
+			received = append(received, event)
+		}
+		done <- true
+	}()
+	timeout(t, 5*time.Second, func() {
+		<-golDone
+		<-done
+	}, "The run should finish even though some subscribers aren't receiving events")
+
+	turn := 0
+	for event := range turns.Events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			turn++
+			assert(t, e.CompletedTurns == turn, "TurnComplete events should arrive in order, expected %v, got %v\n", turn, e.CompletedTurns)
+		case gol.FinalTurnComplete:
+			assert(t, turn == 100, "FinalTurnComplete should follow 100 TurnComplete events, not %v\n", turn)
+		default:
+			t.Errorf("The filtered subscriber should not receive %T events\n", event)
+		}
+	}
+
+	count := 0
+	for range stalled.Events {
+		count++
+	}
+	assert(t, count == len(received), "A queueing subscriber should receive all %v events, not %v\n", len(received), count)
+
+	count = 0
+	for range dropping.Events {
+		count++
+	}
+	assert(t, count <= 5, "A dropping subscriber should hold at most 5 events, not %v\n", count)
+	assert(t, count+dropping.Dropped() == len(received), "Received and dropped events should add up to %v, not %v+%v\n", len(received), count, dropping.Dropped())
+
+	_, open := <-leaving.Events
+	assert(t, !open, "Unsubscribing should close the subscription's channel\n")
+}
+
+// TestBusPolicies tests that coalescing and resyncing subscribers that fall behind still end up with the right world,
+// and that a blocking subscriber holds up the engine.
+func TestBusPolicies(t *testing.T) {
+	bus := gol.NewBus()
+	coalescing := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Coalesce})
+	for x := 0; x < 10; x++ {
+		bus.Publish(gol.CellFlipped{CompletedTurns: 1, Cell: util.Cell{X: x, Y: 0}})
+	}
+	bus.Publish(gol.TurnComplete{CompletedTurns: 1})
+	bus.Publish(gol.CellsFlipped{CompletedTurns: 2, Cells: []util.Cell{{X: 0, Y: 0}}})
+	bus.Publish(gol.CellFlipped{CompletedTurns: 2, Cell: util.Cell{X: 0, Y: 1}})
+	bus.Publish(gol.TurnComplete{CompletedTurns: 2})
+	bus.Close()
+
+	var received []gol.Event
+	world := make(map[util.Cell]bool)
+	for event := range coalescing.Events {
+		received = append(received, event)
+		cells, _ := eventFlips(event)
+		for _, cell := range cells {
+			world[cell] = !world[cell]
+		}
+	}
+	// The first flip may already be on its way to the subscriber, but everything after it is merged,
+	// and (0, 0) is flipped back by the second turn.
+	assert(t, len(received) <= 3, "Flips of both turns should be coalesced into one event before the last TurnComplete, not %v events\n", len(received))
+	last, ok := received[len(received)-1].(gol.TurnComplete)
+	assert(t, ok && last.CompletedTurns == 2, "The last event should be TurnComplete 2, not %v\n", received[len(received)-1])
+	assert(t, !world[util.Cell{X: 0, Y: 0}] && world[util.Cell{X: 0, Y: 1}] && len(received) > 0, "The coalesced flips should leave (0, 0) dead and (0, 1) alive\n")
+	stats := coalescing.Stats()
+	assert(t, stats.Coalesced >= 10 && stats.Delivered == len(received), "Expected at least 10 coalesced events, got %+v\n", stats)
+
+	params := gol.Params{Turns: 100, Threads: 8, ImageWidth: 64, ImageHeight: 64, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	bus = gol.NewBus()
+	resyncing := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Resync, Buffer: 5})
+	golDone := make(chan bool, 1)
+	go func() {
+		gol.Run(params, events, make(chan rune, 10))
+		golDone <- true
+	}()
+	go bus.Forward(events)
+	timeout(t, 5*time.Second, func() { <-golDone }, "The run should finish even though the resyncing subscriber isn't receiving events")
+
+	world = make(map[util.Cell]bool)
+	var final []util.Cell
+	turn := 0
+	for event := range resyncing.Events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			world[e.Cell] = !world[e.Cell]
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				world[cell] = !world[cell]
+			}
+		case gol.TurnComplete:
+			assert(t, e.CompletedTurns > turn, "TurnComplete events should arrive in order, got %v after %v\n", e.CompletedTurns, turn)
+			turn = e.CompletedTurns
+		case gol.FinalTurnComplete:
+			final = e.Alive
+		}
+	}
+	alive := 0
+	for _, flipped := range world {
+		if flipped {
+			alive++
+		}
+	}
+	assert(t, turn == 100, "The resyncing subscriber should catch up to turn 100, not %v\n", turn)
+	assert(t, alive == len(final), "The resynced world should have %v alive cells, not %v\n", len(final), alive)
+	for _, cell := range final {
+		assert(t, world[cell], "Cell (%v, %v) should be alive in the resynced world\n", cell.X, cell.Y)
+	}
+	stats = resyncing.Stats()
+	assert(t, stats.Resyncs > 0 && stats.Dropped > 0, "The resyncing subscriber should have skipped turns, got %+v\n", stats)
+
+	// The engine's channel is unbuffered, so only the subscription's buffer can absorb events.
+	events = make(chan gol.Event)
+	bus = gol.NewBus()
+	blocking := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Block, Buffer: 5})
+	go func() {
+		gol.Run(params, events, make(chan rune, 10))
+		golDone <- true
+	}()
+	go bus.Forward(events)
+	select {
+	case <-golDone:
+		t.Errorf("The run should wait for the blocking subscriber\n")
+	case <-time.After(200 * time.Millisecond):
+	}
+	turn = 0
+	timeout(t, 5*time.Second, func() {
+		for event := range blocking.Events {
+			if e, ok := event.(gol.TurnComplete); ok {
+				turn = e.CompletedTurns
+			}
+		}
+		<-golDone
+	}, "The run should finish once the blocking subscriber receives its events")
+	assert(t, turn == 100 && blocking.Dropped() == 0, "The blocking subscriber should receive every turn without drops\n")
+}
+
+// TestBusBehind tests that coalescing and resyncing subscribers that fall behind a run merge or skip whole turns,
+// so that their world matches check/alive at every TurnComplete they receive.
+func TestBusBehind(t *testing.T) {
+	expected := readAliveCounts(64, 64)
+	for _, policy := range []gol.BufferPolicy{gol.Coalesce, gol.Resync} {
+		t.Run(policy.String(), func(t *testing.T) {
+			// TurnStats is sent between a turn's flips and its TurnComplete.
+			params := gol.Params{Turns: 100, Threads: 8, ImageWidth: 64, ImageHeight: 64, StatsInterval: 1, OutputDir: t.TempDir()}
+			events := make(chan gol.Event, 1000)
+			bus := gol.NewBus()
+			subscription := bus.Subscribe(gol.SubscribeOptions{Policy: policy, Buffer: 5})
+			golDone := make(chan bool, 1)
+			go func() {
+				gol.Run(params, events, make(chan rune, 10))
+				golDone <- true
+			}()
+			go bus.Forward(events)
+			timeout(t, 5*time.Second, func() { <-golDone }, "The run should finish even though the subscriber isn't receiving events")
+
+			world := make(map[util.Cell]bool)
+			alive, turns, last := 0, 0, 0
+			for event := range subscription.Events {
+				cells, flipped := eventFlips(event)
+				for _, cell := range cells {
+					world[cell] = !world[cell]
+					if world[cell] {
+						alive++
+					} else {
+						alive--
+					}
+				}
+				if e, ok := event.(gol.TurnComplete); ok {
+					assert(t, e.CompletedTurns > last, "TurnComplete events should arrive in order, got %v after %v\n", e.CompletedTurns, last)
+					assert(t, alive == expected[e.CompletedTurns], "At TurnComplete %v the world should have %v alive cells, not %v\n", e.CompletedTurns, expected[e.CompletedTurns], alive)
+					last = e.CompletedTurns
+					turns++
+				} else if flipped && last > 0 {
+					assert(t, event.GetCompletedTurns() > last, "Flips received after TurnComplete %v should be of a later turn, not %v\n", last, event.GetCompletedTurns())
+				}
+			}
+			assert(t, last == params.Turns, "The subscriber should catch up to turn %v, not %v\n", params.Turns, last)
+			assert(t, turns < params.Turns, "A subscriber that fell behind shouldn't receive every TurnComplete\n")
+		})
+	}
+}
+
+// eventFlips returns the cells flipped by a CellFlipped or CellsFlipped event.
+func eventFlips(event gol.Event) ([]util.Cell, bool) {
+	switch e := event.(type) {
+	case gol.CellFlipped:
+		return []util.Cell{e.Cell}, true
+	case gol.CellsFlipped:
+		return e.Cells, true
+	}
+	return nil, false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCommands tests that typed commands are applied and acknowledged, and that the flips they cause
+// match a Simulation given the same commands.
+func TestCommands(t *testing.T) {
+	params := gol.Params{Turns: 10000000, Threads: 4, ImageWidth: 16, ImageHeight: 16, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	requests := make(chan gol.Request)
+	var result gol.Result
+	var runErr error
+	golDone := make(chan bool, 1)
+	go func() {
+		result, runErr = gol.RunCommands(context.Background(), params, events, requests)
+		golDone <- true
+	}()
+	var received []gol.Event
+	collected := make(chan bool)
+	go func() {
+		for event := range events {
+			received = append(received, event)
+		}
+		collected <- true
+	}()
+
+	send := func(command gol.Command) error {
+		var err error
+		timeout(t, 5*time.Second, func() { err = <-gol.Send(requests, command) }, "The "+command.String()+" command should be acknowledged")
+		return err
+	}
+	assert(t, send(gol.Pause{}) == nil, "Pause should be acknowledged without an error\n")
+	assert(t, errors.Is(send(gol.Pause{}), gol.ErrPaused), "Pausing twice should be acknowledged with ErrPaused\n")
+	assert(t, send(gol.Step{Turns: 10}) == nil, "Step should be acknowledged without an error\n")
+	assert(t, send(gol.SetCell{Cell: util.Cell{X: 16, Y: 0}, Alive: true}) != nil, "Setting a cell outside the world should fail\n")
+	assert(t, send(gol.SetCell{Cell: util.Cell{X: 0, Y: 0}, Alive: true}) == nil, "SetCell should be acknowledged without an error\n")
+	assert(t, send(gol.SetRule{Rule: "B3/X23"}) != nil, "A rule that isn't in B/S notation should be rejected\n")
+	assert(t, send(gol.SetRule{Rule: "B36/S23"}) == nil, "SetRule should be acknowledged without an error\n")
+	assert(t, send(gol.Step{Turns: 3}) == nil, "Step should be acknowledged without an error\n")
+	assert(t, send(gol.Snapshot{}) == nil, "Snapshot should be acknowledged once the image is written\n")
+	assert(t, send(gol.Resume{}) == nil, "Resume should be acknowledged without an error\n")
+	assert(t, send(gol.Quit{}) == nil, "Quit should be acknowledged without an error\n")
+	timeout(t, 5*time.Second, func() {
+		<-golDone
+		<-collected
+	}, "The run should stop after Quit")
+	assert(t, runErr == nil, "The run should not fail, got %v\n", runErr)
+	assert(t, result.CompletedTurns < params.Turns, "The run should have quit early\n")
+
+	// Replay the commands on a Simulation from the world when the run was paused.
+	paused := -1
+	world := make(map[util.Cell]bool)
+	for _, event := range received {
+		if e, ok := event.(gol.StateChange); ok && e.NewState == gol.Paused {
+			paused = e.CompletedTurns
+			break
+		}
+		if e, ok := event.(gol.CellsFlipped); ok {
+			for _, cell := range e.Cells {
+				world[cell] = !world[cell]
+			}
+		}
+	}
+	assert(t, paused >= 0, "A StateChange Paused event should have been sent\n")
+	sim, err := gol.NewSimulation(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cell, alive := range world {
+		sim.SetCell(cell.X, cell.Y, alive)
+	}
+	sim.Step(10)
+	sim.SetCell(0, 0, true)
+	if err := sim.SetRule("B36/S23"); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(3)
+
+	world = make(map[util.Cell]bool)
+	snapshot := ""
+	var rules []string
+	for _, event := range received {
+		if event.GetCompletedTurns() > paused+13 {
+			break
+		}
+		switch e := event.(type) {
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				world[cell] = !world[cell]
+			}
+		case gol.ImageOutputComplete:
+			snapshot = e.Filename
+		case gol.RuleChange:
+			rules = append(rules, e.Rule)
+		}
+	}
+	var alive []util.Cell
+	for cell, isAlive := range world {
+		if isAlive {
+			alive = append(alive, cell)
+		}
+	}
+	assertEqualBoard(t, alive, sim.Alive(), params)
+	assert(t, snapshot == fmt.Sprintf("16x16x%v", paused+13), "The snapshot should be written for turn %v, not as %v\n", paused+13, snapshot)
+	assert(t, fmt.Sprint(rules) == "[B3/S23 B36/S23]", "RuleChange should report B3/S23 and then B36/S23, not %v\n", rules)
+}
+
+// TestRecordSetCell tests that cells can be set while a recording is sending frames to the io goroutine.
+// Run it with -race: frames must not share rows that SetCell goes on to change.
+func TestRecordSetCell(t *testing.T) {
+	params := gol.Params{Turns: 10000000, Threads: 4, ImageWidth: 16, ImageHeight: 16, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	requests := make(chan gol.Request)
+	golDone := make(chan bool, 1)
+	var runErr error
+	go func() {
+		_, runErr = gol.RunCommands(context.Background(), params, events, requests)
+		golDone <- true
+	}()
+	go func() {
+		for range events {
+		}
+	}()
+
+	send := func(command gol.Command) error {
+		var err error
+		timeout(t, 5*time.Second, func() { err = <-gol.Send(requests, command) }, "The "+command.String()+" command should be acknowledged")
+		return err
+	}
+	assert(t, send(gol.Record{}) == nil, "Record should start a recording without an error\n")
+	for i := 0; i < 200; i++ {
+		// The io goroutine reads the last row of a frame after the distributor has moved on.
+		cell := util.Cell{X: i % params.ImageWidth, Y: params.ImageHeight - 1}
+		assert(t, send(gol.SetCell{Cell: cell, Alive: i%2 == 0}) == nil, "SetCell should be acknowledged without an error\n")
+	}
+	assert(t, send(gol.Record{}) == nil, "Record should write the recording without an error\n")
+	assert(t, send(gol.Quit{}) == nil, "Quit should be acknowledged without an error\n")
+	timeout(t, 5*time.Second, func() { <-golDone }, "The run should stop after Quit")
+	assert(t, runErr == nil, "The run should not fail, got %v\n", runErr)
+}
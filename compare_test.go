@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/sdl"
+)
+
+// TestCompare tests that two runs are compared turn by turn and that the first divergent turn is found.
+func TestCompare(t *testing.T) {
+	params := gol.Params{
+		Turns:       100,
+		Threads:     1,
+		ImageWidth:  16,
+		ImageHeight: 16,
+		OutputDir:   t.TempDir(),
+	}
+
+	threads := params
+	threads.Threads = 8
+	c := compareRuns(params, threads)
+	assert(t, c.Diverged == -1, "Runs with different threads should not diverge, but did at turn %v\n", c.Diverged)
+	assert(t, c.Turn == 100, "Runs should be compared up to turn 100, not %v\n", c.Turn)
+
+	// Add a block far away from the glider, so that the runs differ from the start.
+	data, err := os.ReadFile("images/16x16.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := len(data) - 16*16
+	for _, cell := range [][2]int{{12, 12}, {13, 12}, {12, 13}, {13, 13}} {
+		data[header+cell[1]*16+cell[0]] = 255
+	}
+	changed := params
+	changed.InputPath = filepath.Join(t.TempDir(), "16x16-block.pgm")
+	if err := os.WriteFile(changed.InputPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = compareRuns(params, changed)
+	assert(t, c.Diverged == 0, "Runs with different images should diverge at turn 0, not %v\n", c.Diverged)
+	assert(t, c.Turn == 100, "Runs should be compared up to turn 100, not %v\n", c.Turn)
+	assert(t, c.Differences >= 4, "At least the 4 cells of the block should differ at turn 100, not %v\n", c.Differences)
+}
+
+func compareRuns(first, second gol.Params) *sdl.Comparison {
+	firstEvents := make(chan gol.Event, 1000)
+	secondEvents := make(chan gol.Event, 1000)
+	go gol.Run(first, firstEvents, make(chan rune, 10))
+	go gol.Run(second, secondEvents, make(chan rune, 10))
+	return sdl.CompareHeadless(first, [2]<-chan gol.Event{firstEvents, secondEvents})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestRunContext tests that RunContext returns the final world, and stops when its context is cancelled,
+// even if nobody is receiving events.
+func TestRunContext(t *testing.T) {
+	params := gol.Params{Turns: 100, Threads: 8, ImageWidth: 64, ImageHeight: 64, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	result, err := gol.RunContext(context.Background(), params, events, make(chan rune, 10))
+	assert(t, err == nil, "A finished run should not return an error, got %v\n", err)
+	assert(t, result.CompletedTurns == 100, "The result should be for turn 100, not %v\n", result.CompletedTurns)
+	var final gol.FinalTurnComplete
+	count := 0
+	for event := range events {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e
+		}
+		count++
+	}
+	assert(t, count > 0, "Events should have been sent\n")
+	assertEqualBoard(t, result.Alive, final.Alive, params)
+	assert(t, len(result.World) == 64 && len(result.World[0]) == 64, "The result should hold the 64x64 world\n")
+
+	// Nobody receives events after turn 10, so only cancelling can stop the run.
+	params.Turns = 10000000
+	params.ImageWidth, params.ImageHeight = 512, 512
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events = make(chan gol.Event)
+	done := make(chan bool)
+	go func() {
+		result, err = gol.RunContext(ctx, params, events, make(chan rune, 10))
+		done <- true
+	}()
+	for event := range events {
+		if e, ok := event.(gol.TurnComplete); ok && e.CompletedTurns == 10 {
+			break
+		}
+	}
+	cancel()
+	timeout(t, 10*time.Second, func() { <-done }, "RunContext should return once its context is cancelled")
+	assert(t, errors.Is(err, context.Canceled), "A cancelled run should return context.Canceled, not %v\n", err)
+	assert(t, result.CompletedTurns >= 10 && result.CompletedTurns < params.Turns, "A cancelled run should stop early, not at turn %v\n", result.CompletedTurns)
+	assert(t, len(result.Alive) > 0, "The result should hold the alive cells of the last turn\n")
+	for range events {
+	}
+	filename := filepath.Join(params.OutputDir, fmt.Sprintf("512x512x%v.pgm", result.CompletedTurns))
+	_, statErr := os.Stat(filename)
+	assert(t, statErr == nil, "A cancelled run should still write its final image, %v\n", statErr)
+
+	// Cancelling once the last turn is complete doesn't stop the run, so it still succeeds.
+	params.Turns = 10
+	params.ImageWidth, params.ImageHeight = 64, 64
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	events = make(chan gol.Event)
+	go func() {
+		result, err = gol.RunContext(ctx, params, events, make(chan rune, 10))
+		done <- true
+	}()
+	for event := range events {
+		if _, ok := event.(gol.FinalTurnComplete); ok {
+			cancel()
+		}
+	}
+	timeout(t, 10*time.Second, func() { <-done }, "RunContext should return once the run finishes")
+	assert(t, err == nil, "A run that finished before it was cancelled should not return an error, got %v\n", err)
+	assert(t, result.CompletedTurns == 10, "The result should be for turn 10, not %v\n", result.CompletedTurns)
+}
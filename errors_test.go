@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestErrors tests that io failures are reported as an ErrorEvent followed by a clean shutdown.
+func TestErrors(t *testing.T) {
+	t.Run("missing input", testErrorsMissingInput)
+	t.Run("unwritable output", testErrorsUnwritableOutput)
+}
+
+func testErrorsMissingInput(t *testing.T) {
+	p := gol.Params{
+		Turns:       10,
+		Threads:     8,
+		ImageWidth:  17,
+		ImageHeight: 16,
+	}
+	err := awaitErrorEvent(t, p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ERROR: Expected a file not found error, got %v instead", err)
+	}
+}
+
+func testErrorsUnwritableOutput(t *testing.T) {
+	p := gol.Params{
+		Turns:       0,
+		Threads:     8,
+		ImageWidth:  16,
+		ImageHeight: 16,
+	}
+
+	// A file named out stops the io goroutine from creating images inside it.
+	os.RemoveAll("out")
+	util.Check(os.WriteFile("out", nil, 0644))
+	defer emptyOutFolder()
+
+	awaitErrorEvent(t, p)
+}
+
+// awaitErrorEvent runs gol.Run and checks that it sends an ErrorEvent, then StateChange Quitting, then closes the events channel.
+func awaitErrorEvent(t *testing.T, p gol.Params) error {
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+
+	var err error
+	quitting := false
+	done := make(chan bool)
+	go func() {
+		for event := range events {
+			switch e := event.(type) {
+			case gol.ErrorEvent:
+				err = e.Err
+			case gol.StateChange:
+				quitting = e.NewState == gol.Quitting
+			}
+		}
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ERROR: The events channel was not closed in 2 seconds")
+	}
+	if err == nil {
+		t.Error("ERROR: No ErrorEvent received")
+	}
+	if !quitting {
+		t.Error("ERROR: The last StateChange event should have a NewState of Quitting")
+	}
+	return err
+}
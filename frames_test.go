@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestFrames tests that headless runs record the window's frames to numbered PNG images.
+func TestFrames(t *testing.T) {
+	dir := t.TempDir()
+	params := gol.Params{
+		Turns:         100,
+		Threads:       8,
+		ImageWidth:    512,
+		ImageHeight:   512,
+		OutputDir:     dir,
+		FrameInterval: 50,
+		FrameScale:    2,
+	}
+
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	go gol.Run(params, events, keyPresses)
+	sdl.RunHeadless(params, events)
+
+	// The overlay covers the top of each frame, so only the rows below it are compared.
+	const below = 160
+	for frame, turn := range []int{0, 50, 100} {
+		path := filepath.Join(dir, "frames", fmt.Sprintf("frame-%06d.png", frame))
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Frame %v should have been written for turn %v: %v\n", frame, turn, err)
+		}
+		pixels, err := png.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := pixels.Bounds().Size()
+		assert(t, size.X == 1024 && size.Y == 1024, "Frame %v should be 1024x1024, not %vx%v\n", frame, size.X, size.Y)
+
+		if turn == 50 {
+			continue
+		}
+		expected := readAliveCells(fmt.Sprintf("check/images/512x512x%v.pgm", turn), 512, 512)
+		assertEqualBoard(t, cellsBelow(imageAliveCells(pixels, 2), below), cellsBelow(expected, below), params)
+	}
+
+	_, err := os.Stat(filepath.Join(dir, "frames", "frame-000003.png"))
+	assert(t, os.IsNotExist(err), "Only 3 frames should be written for 100 turns every 50 turns\n")
+}
+
+func cellsBelow(cells []util.Cell, y int) []util.Cell {
+	var below []util.Cell
+	for _, cell := range cells {
+		if cell.Y >= y {
+			below = append(below, cell)
+		}
+	}
+	return below
+}
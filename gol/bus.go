@@ -0,0 +1,449 @@
+package gol
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// BufferPolicy decides what a subscription does with events its subscriber hasn't taken yet.
+type BufferPolicy int
+
+const (
+	// Queue keeps every event until the subscriber takes it, however far behind it falls.
+	Queue BufferPolicy = iota
+	// Drop keeps at most SubscribeOptions.Buffer events and discards new ones while the buffer is full.
+	Drop
+	// Block makes the publisher wait while the buffer is full, so a slow subscriber slows the engine down.
+	Block
+	// Coalesce merges flip events into a single CellsFlipped while they wait to be received, including
+	// those of later turns, so a subscriber that falls behind receives the net flips and the latest TurnComplete.
+	Coalesce
+	// Resync skips whole turns while the buffer is full. When there is space again, the subscriber is sent
+	// the net flips of the skipped turns as one CellsFlipped, then the latest TurnComplete, which brings
+	// its world back in sync. Other events are never skipped.
+	Resync
+)
+
+var bufferPolicies = []BufferPolicy{Queue, Drop, Block, Coalesce, Resync}
+
+func (policy BufferPolicy) String() string {
+	switch policy {
+	case Queue:
+		return "Queue"
+	case Drop:
+		return "Drop"
+	case Block:
+		return "Block"
+	case Coalesce:
+		return "Coalesce"
+	case Resync:
+		return "Resync"
+	default:
+		return "Incorrect BufferPolicy"
+	}
+}
+
+// ParseBufferPolicy returns the policy with the given name, ignoring case.
+func ParseBufferPolicy(name string) (BufferPolicy, error) {
+	for _, policy := range bufferPolicies {
+		if strings.EqualFold(policy.String(), name) {
+			return policy, nil
+		}
+	}
+	return Queue, fmt.Errorf("unknown buffer policy %q", name)
+}
+
+// SubscribeOptions selects which events a subscriber receives and how they are buffered.
+type SubscribeOptions struct {
+	// Filter reports whether the subscriber wants an event. All events are sent when it is nil.
+	Filter func(Event) bool
+	Policy BufferPolicy
+	// Buffer is the number of events a Drop, Block or Resync subscription holds. At least 1 is used.
+	Buffer int
+}
+
+// SubscriptionStats counts what a subscription's policy did with the events offered to it.
+type SubscriptionStats struct {
+	// Delivered is the number of events received by the subscriber.
+	Delivered int
+	// Coalesced is the number of flip events merged into another CellsFlipped,
+	// and of TurnCompletes dropped between the turns merged.
+	Coalesced int
+	// Dropped is the number of events discarded, including the flips and turns skipped by Resync.
+	Dropped int
+	// Resyncs is the number of times Resync brought the subscriber back in sync.
+	Resyncs int
+}
+
+// Only returns a filter that accepts events of the same types as the given examples,
+// e.g. Only(AliveCellsCount{}, FinalTurnComplete{}).
+func Only(examples ...Event) func(Event) bool {
+	types := make(map[reflect.Type]bool)
+	for _, example := range examples {
+		types[reflect.TypeOf(example)] = true
+	}
+	return func(event Event) bool {
+		return types[reflect.TypeOf(event)]
+	}
+}
+
+// Subscription is a subscriber's view of a Bus.
+type Subscription struct {
+	// Events receives the subscribed events, in the order they were published.
+	// It is closed once the bus is closed and every buffered event has been received.
+	Events <-chan Event
+
+	bus     *Bus
+	options SubscribeOptions
+	out     chan Event
+	mu      sync.Mutex
+	queue   []Event
+	sending bool
+	space   *sync.Cond
+	wake    chan struct{}
+	stop    chan struct{}
+	closed  bool
+	stats   SubscriptionStats
+
+	// skipping is set while Resync skips turns. flips holds the cells flipped an odd number of times since,
+	// flipped the turn of the last flips skipped, and turn the last skipped TurnComplete, if any.
+	// partial is set while the flips of a turn have been skipped but its TurnComplete hasn't arrived yet.
+	skipping bool
+	flips    map[util.Cell]bool
+	flipped  int
+	turn     *TurnComplete
+	partial  bool
+}
+
+// Dropped returns how many events were discarded because the subscriber fell behind.
+func (s *Subscription) Dropped() int {
+	return s.Stats().Dropped
+}
+
+// Stats returns what the subscription's policy has done so far.
+func (s *Subscription) Stats() SubscriptionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Unsubscribe stops the subscription, discarding any buffered events and closing Events.
+// It must only be called once.
+func (s *Subscription) Unsubscribe() {
+	// Closing first releases a publisher blocked on this subscription, which holds the bus.
+	close(s.stop)
+	s.close()
+	s.mu.Lock()
+	s.queue = nil
+	s.mu.Unlock()
+	s.bus.remove(s)
+}
+
+// offer buffers an event for the subscriber according to its policy.
+// Only the Block policy ever waits, until the subscriber has taken enough events to make space.
+func (s *Subscription) offer(event Event) {
+	if s.options.Filter != nil && !s.options.Filter(event) {
+		return
+	}
+	s.mu.Lock()
+	if !s.closed {
+		switch s.options.Policy {
+		case Drop:
+			if s.buffered() >= s.capacity() {
+				s.stats.Dropped++
+			} else {
+				s.queue = append(s.queue, event)
+			}
+		case Block:
+			for s.buffered() >= s.capacity() && !s.closed {
+				s.space.Wait()
+			}
+			s.queue = append(s.queue, event)
+		case Coalesce:
+			s.coalesce(event)
+		case Resync:
+			s.resync(event)
+		default:
+			s.queue = append(s.queue, event)
+		}
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *Subscription) capacity() int {
+	if s.options.Buffer < 1 {
+		return 1
+	}
+	return s.options.Buffer
+}
+
+// flippedCells returns the cells of a CellFlipped or CellsFlipped event.
+func flippedCells(event Event) ([]util.Cell, bool) {
+	switch e := event.(type) {
+	case CellFlipped:
+		return []util.Cell{e.Cell}, true
+	case CellsFlipped:
+		return e.Cells, true
+	}
+	return nil, false
+}
+
+// coalesce queues an event, merging flips into the last queued event if it holds flips.
+// A TurnComplete still queued when the next turn's flips arrive means the subscriber is behind,
+// so it is dropped and the flips of both turns are merged. It must be called with s.mu held.
+func (s *Subscription) coalesce(event Event) {
+	cells, flipped := flippedCells(event)
+	if !flipped {
+		s.queue = append(s.queue, event)
+		return
+	}
+	if n := len(s.queue); n > 0 {
+		if _, ok := s.queue[n-1].(TurnComplete); ok {
+			s.queue[n-1] = nil
+			s.queue = s.queue[:n-1]
+			s.stats.Coalesced++
+		}
+	}
+	if n := len(s.queue); n > 0 {
+		if queued, ok := flippedCells(s.queue[n-1]); ok {
+			s.queue[n-1] = CellsFlipped{event.GetCompletedTurns(), mergeFlips(queued, cells)}
+			s.stats.Coalesced++
+			return
+		}
+	}
+	s.queue = append(s.queue, event)
+}
+
+// mergeFlips returns the cells flipped an odd number of times by a and then b, in a new slice,
+// as the engine's slices are never changed.
+func mergeFlips(a, b []util.Cell) []util.Cell {
+	odd := make(map[util.Cell]bool, len(a)+len(b))
+	for _, cell := range a {
+		odd[cell] = !odd[cell]
+	}
+	for _, cell := range b {
+		odd[cell] = !odd[cell]
+	}
+	cells := make([]util.Cell, 0, len(odd))
+	for _, cell := range append(a, b...) {
+		if odd[cell] {
+			cells = append(cells, cell)
+			odd[cell] = false
+		}
+	}
+	return cells
+}
+
+// resync queues an event, or skips it if it is part of a turn that the subscriber has no space for.
+// It must be called with s.mu held.
+func (s *Subscription) resync(event Event) {
+	cells, flipped := flippedCells(event)
+	turn, completed := event.(TurnComplete)
+	if !flipped && !completed {
+		// Other events are never skipped. Between turns, the subscriber is brought up to date first,
+		// but flips skipped part way through a turn are only caught up with at its TurnComplete.
+		if !s.partial {
+			s.catchUp()
+		}
+		s.queue = append(s.queue, event)
+		return
+	}
+
+	if !s.skipping && s.buffered() < s.capacity() {
+		s.queue = append(s.queue, event)
+		return
+	}
+	if !s.skipping {
+		s.skipping = true
+		s.flips = make(map[util.Cell]bool)
+	}
+	for _, cell := range cells {
+		s.flips[cell] = !s.flips[cell]
+	}
+	if flipped {
+		s.flipped = event.GetCompletedTurns()
+		s.partial = true
+	}
+	s.stats.Dropped++
+	if completed {
+		s.turn = &turn
+		s.partial = false
+		// Turns are only ever resumed at a TurnComplete, so the subscriber never sees part of a turn.
+		if s.buffered() < s.capacity() {
+			s.catchUp()
+		}
+	}
+}
+
+// catchUp queues the net flips and the last TurnComplete skipped by Resync. It must be called with s.mu held.
+func (s *Subscription) catchUp() {
+	if !s.skipping {
+		return
+	}
+	var cells []util.Cell
+	for cell, flipped := range s.flips {
+		if flipped {
+			cells = append(cells, cell)
+		}
+	}
+	if len(cells) > 0 {
+		s.queue = append(s.queue, CellsFlipped{s.flipped, cells})
+	}
+	if s.turn != nil {
+		s.queue = append(s.queue, *s.turn)
+	}
+	s.stats.Resyncs++
+	s.skipping = false
+	s.flips = nil
+	s.turn = nil
+	s.partial = false
+}
+
+// buffered counts the events waiting for the subscriber, including one being sent. It must be called with s.mu held.
+func (s *Subscription) buffered() int {
+	if s.sending {
+		return len(s.queue) + 1
+	}
+	return len(s.queue)
+}
+
+// backlog returns the number of events waiting for the subscriber, and the most its policy buffers,
+// which is 0 for Queue and Coalesce as they buffer any number.
+func (s *Subscription) backlog() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.options.Policy {
+	case Drop, Block, Resync:
+		return s.buffered(), s.capacity()
+	}
+	return s.buffered(), 0
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	s.catchUp()
+	s.closed = true
+	s.space.Broadcast()
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends buffered events to the subscriber, so that only this goroutine waits for a slow one.
+func (s *Subscription) deliver() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			<-s.wake
+			continue
+		}
+		event := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.sending = true
+		s.mu.Unlock()
+
+		select {
+		case s.out <- event:
+		case <-s.stop:
+			return
+		}
+		s.mu.Lock()
+		s.sending = false
+		s.stats.Delivered++
+		s.space.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// Bus fans the events of one simulation out to any number of subscribers.
+// Publishing only waits for Block subscribers, so other slow subscribers can't stall the engine.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers []*Subscription
+	closed      bool
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a new subscriber, which receives the events published from now on.
+func (b *Bus) Subscribe(options SubscribeOptions) *Subscription {
+	out := make(chan Event)
+	s := &Subscription{
+		Events:  out,
+		bus:     b,
+		options: options,
+		out:     out,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	s.space = sync.NewCond(&s.mu)
+	go s.deliver()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.close()
+	} else {
+		b.subscribers = append(b.subscribers, s)
+	}
+	return s
+}
+
+func (b *Bus) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, subscriber := range b.subscribers {
+		if subscriber == s {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish offers an event to every subscriber, waiting while a Block subscriber's buffer is full.
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subscribers {
+		s.offer(event)
+	}
+}
+
+// Close ends every subscription once its subscriber has received the events already buffered.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, s := range b.subscribers {
+		s.close()
+	}
+	b.subscribers = nil
+}
+
+// Forward publishes every event from events, e.g. the channel given to Run, and closes the bus once it is closed.
+func (b *Bus) Forward(events <-chan Event) {
+	for event := range events {
+		b.Publish(event)
+	}
+	b.Close()
+}
@@ -0,0 +1,168 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Command is a typed instruction for a running simulation, sent to RunCommands in a Request.
+type Command interface {
+	fmt.Stringer
+	command()
+}
+
+// Pause stops the simulation between turns until Resume. It is acknowledged once StateChange Paused is sent.
+type Pause struct{}
+
+// Resume carries on with a paused simulation. It is acknowledged once StateChange Executing is sent.
+type Resume struct{}
+
+// Snapshot writes the current world as an image, and is acknowledged once it has been written.
+type Snapshot struct{}
+
+// Record starts recording an animated GIF, or writes the recording in progress.
+type Record struct{}
+
+// Quit stops the simulation after writing the final world.
+type Quit struct{}
+
+// Kill stops the simulation like Quit.
+type Kill struct{}
+
+// Step completes Turns turns straight away, also while paused, but never goes past Params.Turns.
+type Step struct {
+	Turns int
+}
+
+// SetCell brings a cell alive or kills it, sending a CellsFlipped event if it changed.
+type SetCell struct {
+	Cell  util.Cell
+	Alive bool
+}
+
+// SetRule changes the rule used from the next turn, in B/S notation such as B36/S23.
+// Images are named after the new rule, and macrocell images record it.
+type SetRule struct {
+	Rule string
+}
+
+// togglePause pauses a running simulation and resumes a paused one, like the 'p' key.
+type togglePause struct{}
+
+func (Pause) command()       {}
+func (Resume) command()      {}
+func (Snapshot) command()    {}
+func (Record) command()      {}
+func (Quit) command()        {}
+func (Kill) command()        {}
+func (Step) command()        {}
+func (SetCell) command()     {}
+func (SetRule) command()     {}
+func (togglePause) command() {}
+
+func (Pause) String() string       { return "Pause" }
+func (Resume) String() string      { return "Resume" }
+func (Snapshot) String() string    { return "Snapshot" }
+func (Record) String() string      { return "Record" }
+func (Quit) String() string        { return "Quit" }
+func (Kill) String() string        { return "Kill" }
+func (togglePause) String() string { return "Pause or Resume" }
+
+func (command Step) String() string {
+	return fmt.Sprintf("Step %v", command.Turns)
+}
+
+func (command SetCell) String() string {
+	return fmt.Sprintf("Set Cell (%v, %v) Alive %v", command.Cell.X, command.Cell.Y, command.Alive)
+}
+
+func (command SetRule) String() string {
+	return fmt.Sprintf("Set Rule %v", command.Rule)
+}
+
+var (
+	// ErrPaused acknowledges a Pause sent while the simulation is already paused.
+	ErrPaused = errors.New("the simulation is already paused")
+	// ErrNotPaused acknowledges a Resume sent while the simulation is running.
+	ErrNotPaused = errors.New("the simulation is not paused")
+)
+
+// Request is a command for RunCommands, with somewhere to acknowledge it.
+type Request struct {
+	Command Command
+	// Ack, when not nil, receives nil once the command has been applied, or why it couldn't be.
+	// The simulation doesn't wait for it to be received, so it needs room for one error.
+	Ack chan<- error
+}
+
+// Send sends a command on requests and returns the channel that acknowledges it.
+func Send(requests chan<- Request, command Command) <-chan error {
+	ack := make(chan error, 1)
+	requests <- Request{command, ack}
+	return ack
+}
+
+func (r Request) acknowledge(err error) {
+	acknowledge(r.Ack, err)
+}
+
+// acknowledge sends the outcome of a command on ack, without waiting, unless ack is nil.
+func acknowledge(ack chan<- error, err error) {
+	if ack == nil {
+		return
+	}
+	select {
+	case ack <- err:
+	default:
+	}
+}
+
+// KeyCommand returns the command for a key press sent to Run: 'p' pauses or resumes, 's' takes a snapshot,
+// 'r' records, 'n' steps a single turn, 'q' quits and 'k' kills the simulation. Other keys have no command.
+func KeyCommand(key rune) (Command, bool) {
+	switch key {
+	case 'p':
+		return togglePause{}, true
+	case 's':
+		return Snapshot{}, true
+	case 'r':
+		return Record{}, true
+	case 'n':
+		return Step{Turns: 1}, true
+	case 'q':
+		return Quit{}, true
+	case 'k':
+		return Kill{}, true
+	}
+	return nil, false
+}
+
+// keyRequests turns key presses into requests until keyPresses is closed or done is.
+func keyRequests(keyPresses <-chan rune, done <-chan struct{}) <-chan Request {
+	requests := make(chan Request)
+	go func() {
+		defer close(requests)
+		for {
+			select {
+			case key, ok := <-keyPresses:
+				if !ok {
+					return
+				}
+				command, ok := KeyCommand(key)
+				if !ok {
+					continue
+				}
+				select {
+				case requests <- Request{Command: command}:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return requests
+}
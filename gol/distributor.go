@@ -1,32 +1,551 @@
 package gol
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Rule is the rule of the Game of Life, in B/S notation, which runs follow unless Params.Rule is set.
+const Rule = "B3/S23"
+
 type distributorChannels struct {
 	events     chan<- Event
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
+	ioErrors   <-chan error
+	ioBusy     chan struct{}
+	ioFailures chan error
 	ioFilename chan<- string
-	ioOutput   chan<- uint8
+	ioRule     chan<- string
+	ioOutput   chan<- []byte
 	ioInput    <-chan uint8
+	requests   <-chan Request
+	metrics    *Metrics
+	viewport   *Viewport
+	done       <-chan struct{}
 }
 
-// distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
+// errCancelled is returned by simulate when the run was stopped by c.done, rather than finishing or failing.
+var errCancelled = errors.New("run cancelled")
+
+// workerResult is the slice of the next world computed by a single worker.
+type workerResult struct {
+	worker   int
+	startY   int
+	rows     [][]byte
+	flipped  []util.Cell
+	alive    int
+	duration time.Duration
+}
 
-	// TODO: Create a 2D slice to store the world.
+// makeWorld allocates an empty world of the given size.
+func makeWorld(height, width int) [][]byte {
+	world := make([][]byte, height)
+	for i := range world {
+		world[i] = make([]byte, width)
+	}
+	return world
+}
 
-	turn := 0
-	c.events <- StateChange{turn, Executing}
+// aliveNeighbours counts the alive neighbours of (x, y) on a closed (wrapping) domain.
+func aliveNeighbours(world [][]byte, x, y, width, height int) int {
+	count := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			if world[(y+dy+height)%height][(x+dx+width)%width] != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// worker computes rows [startY, endY) of the next world under rule and reports which cells flipped.
+func worker(p Params, world [][]byte, rule *ruleset, id, startY, endY int, out chan<- workerResult) {
+	start := time.Now()
+	rows := makeWorld(endY-startY, p.ImageWidth)
+	var flipped []util.Cell
+	alive := 0
+	for y := startY; y < endY; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			neighbours := aliveNeighbours(world, x, y, p.ImageWidth, p.ImageHeight)
+			wasAlive := world[y][x] != 0
+			if (wasAlive && rule.survive[neighbours]) || (!wasAlive && rule.birth[neighbours]) {
+				rows[y-startY][x] = 255
+				alive++
+			}
+			if (rows[y-startY][x] != 0) != wasAlive {
+				flipped = append(flipped, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	out <- workerResult{id, startY, rows, flipped, alive, time.Since(start)}
+}
+
+// calculateNextState splits the world between p.Threads workers and assembles the next world.
+// How long each worker took is recorded in metrics.
+func calculateNextState(p Params, world [][]byte, rule *ruleset, metrics *Metrics) ([][]byte, []util.Cell) {
+	threads := p.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	if threads > p.ImageHeight {
+		threads = p.ImageHeight
+	}
+
+	out := make(chan workerResult, threads)
+	for i := 0; i < threads; i++ {
+		startY := i * p.ImageHeight / threads
+		endY := (i + 1) * p.ImageHeight / threads
+		go worker(p, world, rule, i, startY, endY, out)
+	}
+
+	newWorld := make([][]byte, p.ImageHeight)
+	var flipped []util.Cell
+	results := make([]workerResult, threads)
+	for i := 0; i < threads; i++ {
+		result := <-out
+		copy(newWorld[result.startY:], result.rows)
+		flipped = append(flipped, result.flipped...)
+		results[i] = result
+	}
+	metrics.step(results)
+	return newWorld, flipped
+}
+
+// calculateAliveCells returns every alive cell in the world.
+func calculateAliveCells(world [][]byte) []util.Cell {
+	var alive []util.Cell
+	for y, row := range world {
+		for x, cell := range row {
+			if cell != 0 {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return alive
+}
+
+// acquireIo waits until no background output is in progress and reserves the io goroutine.
+// Every conversation with the io goroutine must happen between acquireIo and releaseIo.
+func acquireIo(c distributorChannels) {
+	c.ioBusy <- struct{}{}
+	c.metrics.ioAcquire()
+}
+
+// releaseIo lets the next conversation with the io goroutine begin.
+func releaseIo(c distributorChannels) {
+	c.metrics.ioRelease()
+	<-c.ioBusy
+}
+
+// awaitIo waits until any background output has finished and returns the first error it reported.
+func awaitIo(c distributorChannels) error {
+	acquireIo(c)
+	releaseIo(c)
+	select {
+	case err := <-c.ioFailures:
+		return err
+	default:
+		return nil
+	}
+}
+
+// reportIoFailure records an error from background output, keeping only the first one.
+func reportIoFailure(c distributorChannels, err error) {
+	select {
+	case c.ioFailures <- err:
+	default:
+	}
+}
+
+// loadWorld asks the io goroutine for the initial image and converts it into a world.
+func loadWorld(p Params, c distributorChannels) ([][]byte, error) {
+	acquireIo(c)
+	defer releaseIo(c)
+
+	c.ioCommand <- ioInput
+	c.ioFilename <- fmt.Sprintf("%vx%v", p.ImageWidth, p.ImageHeight)
+	if err := <-c.ioErrors; err != nil {
+		return nil, err
+	}
+
+	world := makeWorld(p.ImageHeight, p.ImageWidth)
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			world[y][x] = <-c.ioInput
+		}
+	}
+	return world, nil
+}
+
+// sendWorld streams the world to the io goroutine, row by row.
+// The rows are shared rather than copied, as a world is never modified once it has been computed.
+func sendWorld(p Params, c distributorChannels, world [][]byte) {
+	for y := 0; y < p.ImageHeight; y++ {
+		c.ioOutput <- world[y]
+	}
+}
+
+// saveWorld asks the io goroutine to output the world in the background, so that the workers
+// can carry on with the next turns while the image is written. This is safe because a world
+// is never modified once it has been computed. Failures are reported on c.ioFailures,
+// and the outcome is sent on ack if it isn't nil.
+func saveWorld(p Params, c distributorChannels, world [][]byte, turn int, ack chan<- error) {
+	acquireIo(c)
+	go func() {
+		defer releaseIo(c)
+
+		filename := outputFilename(p, outputFormat(p), strconv.Itoa(turn))
+		c.ioCommand <- ioOutput
+		c.ioRule <- ruleName(p)
+		c.ioFilename <- filename
+		sendWorld(p, c, world)
+		if err := <-c.ioErrors; err != nil {
+			reportIoFailure(c, err)
+			acknowledge(ack, err)
+			return
+		}
+
+		c.ioCommand <- ioCheckIdle
+		<-c.ioIdle
+		// The event can wait for the front-end, which the io goroutine is not busy with.
+		c.metrics.ioRelease()
+		c.events <- ImageOutputComplete{turn, filename}
+		acknowledge(ack, nil)
+	}()
+}
+
+// gifRecorder tracks which turns the distributor sends to the io goroutine's GIF encoder.
+type gifRecorder struct {
+	active   bool
+	from     int
+	interval int
+	// frames counts the frames the io goroutine is holding for the current file.
+	frames int
+}
 
-	// TODO: Execute all turns of the Game of Life.
+// start begins a new recording with the current world as its first frame.
+func (r *gifRecorder) start(p Params, c distributorChannels, sim *Simulation) {
+	r.active = true
+	r.from = sim.Turn()
+	r.capture(p, c, sim)
+}
+
+// capture sends the world as a frame if a recording is active and the turn is due.
+// The io goroutine keeps the rows while it encodes them, so the world is taken with World,
+// which makes a later SetCell copy it rather than change the frame.
+// Once the frames would take more than MaxGifBytes, the GIF is written and recording carries on in a new file.
+func (r *gifRecorder) capture(p Params, c distributorChannels, sim *Simulation) {
+	if !r.active || (sim.Turn()-r.from)%r.interval != 0 {
+		return
+	}
+	acquireIo(c)
+	c.ioCommand <- ioRecordFrame
+	sendWorld(p, c, sim.World())
+	releaseIo(c)
+
+	r.frames++
+	if r.frames < gifFrameLimit(p) {
+		return
+	}
+	turn := sim.Turn()
+	if err := r.stop(p, c, turn); err != nil {
+		reportIoFailure(c, err)
+		return
+	}
+	r.active = true
+	r.from = turn
+}
+
+// gifFrameLimit returns the number of frames that fit in MaxGifBytes, which is always at least 1.
+func gifFrameLimit(p Params) int {
+	scale := p.GifScale
+	if scale < 1 {
+		scale = 1
+	}
+	limit := MaxGifBytes / (p.ImageWidth * scale * p.ImageHeight * scale)
+	if limit < 1 {
+		return 1
+	}
+	return limit
+}
+
+// stop asks the io goroutine to write the recording and waits until the GIF is written.
+func (r *gifRecorder) stop(p Params, c distributorChannels, turn int) error {
+	if !r.active {
+		return nil
+	}
+	r.active = false
+	r.frames = 0
+
+	acquireIo(c)
+	defer releaseIo(c)
 
-	// TODO: Report the final state using FinalTurnCompleteEvent.
+	filename := outputFilename(p, gifFormat, fmt.Sprintf("%v-%v", r.from, turn))
+	c.ioCommand <- ioRecordFinish
+	c.ioFilename <- filename
+	if err := <-c.ioErrors; err != nil {
+		return err
+	}
+
+	c.ioCommand <- ioCheckIdle
+	<-c.ioIdle
+	c.metrics.ioRelease()
+	c.events <- ImageOutputComplete{turn, filename}
+	return nil
+}
+
+// toggle starts a recording, or stops the one in progress.
+func (r *gifRecorder) toggle(p Params, c distributorChannels, sim *Simulation) error {
+	if r.active {
+		return r.stop(p, c, sim.Turn())
+	}
+	r.start(p, c, sim)
+	return nil
+}
+
+// distributor divides the work between workers and interacts with other goroutines.
+// If the run fails, the error is reported as an ErrorEvent before quitting, and returned.
+// If it was stopped by c.done, errCancelled is returned instead.
+func distributor(p Params, c distributorChannels) (Result, error) {
+	turn, world, err := simulate(p, c)
+	if err != nil && err != errCancelled {
+		c.events <- ErrorEvent{turn, err}
+	}
 
 	// Make sure that the Io has finished any output before exiting.
+	acquireIo(c)
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
+	releaseIo(c)
 
 	c.events <- StateChange{turn, Quitting}
 
 	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
 	close(c.events)
+
+	result := Result{CompletedTurns: turn, World: world}
+	if world != nil {
+		result.Alive = calculateAliveCells(world)
+	}
+	return result, err
+}
+
+// simulate loads the world, executes all turns and outputs the final state.
+// It returns the number of completed turns, the last world, and the first io error that stopped the run.
+// Snapshots are written in the background, so their errors are only noticed between turns.
+func simulate(p Params, c distributorChannels) (int, [][]byte, error) {
+	turn := 0
+	rule, err := parseRule(ruleName(p))
+	if err != nil {
+		return turn, nil, err
+	}
+	world, err := loadWorld(p, c)
+	if err != nil {
+		return turn, nil, err
+	}
+	sim := newSimulationFromWorld(p, rule, world)
+	sim.metrics = c.metrics
+	// Images are named after the rule in use, which SetRule changes.
+	p.Rule = rule.name
+	r := &runState{
+		p:        p,
+		sim:      sim,
+		view:     newViewTracker(p, c.viewport),
+		stats:    newStatsCounter(p),
+		recorder: gifRecorder{interval: 1},
+		requests: c.requests,
+	}
+
+	alive := sim.Alive()
+	c.metrics.loaded(len(alive))
+	if flipped := r.view.flips(p, world, alive); len(flipped) > 0 {
+		c.events <- CellsFlipped{turn, flipped}
+	}
+	if r.stats.due(turn) {
+		c.events <- r.stats.report(turn, world)
+	}
+	c.events <- RuleChange{turn, rule.name}
+	c.events <- StateChange{turn, Executing}
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	if p.GifInterval > 0 {
+		r.recorder.interval = p.GifInterval
+		r.recorder.start(p, c, sim)
+	}
+
+	quit := false
+	for sim.Turn() < p.Turns && !quit {
+		select {
+		case <-ticker.C:
+			c.events <- AliveCellsCount{sim.Turn(), len(sim.Alive())}
+		case err := <-c.ioFailures:
+			return sim.Turn(), sim.World(), err
+		case <-c.done:
+			quit = true
+			r.cancelled = true
+		case <-r.view.changes():
+			r.moved(c)
+		case request, ok := <-r.requests:
+			if !ok {
+				// Nobody is left to send commands, so the run carries on to the last turn.
+				r.requests = nil
+				break
+			}
+			quit, err = r.handle(c, request)
+			if err != nil {
+				return sim.Turn(), sim.World(), err
+			}
+		default:
+			r.advance(c)
+		}
+	}
+
+	turn, world = sim.Turn(), sim.World()
+	c.events <- FinalTurnComplete{turn, calculateAliveCells(world)}
+	if err := r.recorder.stop(r.p, c, turn); err != nil {
+		return turn, world, err
+	}
+	saveWorld(r.p, c, world, turn, nil)
+	if err := awaitIo(c); err != nil || !r.cancelled {
+		return turn, world, err
+	}
+	return turn, world, errCancelled
+}
+
+// runState is the state of a run that commands act on.
+type runState struct {
+	// p.Rule is the rule in use, so that images are named after it.
+	p        Params
+	sim      *Simulation
+	view     *viewTracker
+	stats    *statsCounter
+	recorder gifRecorder
+	requests <-chan Request
+	// cancelled is set once c.done has stopped the run.
+	cancelled bool
+}
+
+// advance completes a turn and sends its events.
+func (r *runState) advance(c distributorChannels) {
+	flipped := r.sim.step()
+	world, turn := r.sim.world, r.sim.Turn()
+	r.stats.count(world, flipped)
+	flipped = r.view.flips(r.p, world, flipped)
+	c.metrics.completed(turn)
+	if len(flipped) > 0 {
+		c.events <- CellsFlipped{turn, flipped}
+	}
+	if r.stats.due(turn) {
+		c.events <- r.stats.report(turn, world)
+	}
+	c.events <- TurnComplete{turn}
+	r.recorder.capture(r.p, c, r.sim)
+}
+
+// moved flips the cells that came into view when the viewport moved.
+func (r *runState) moved(c distributorChannels) {
+	if flipped := r.view.moved(r.p, r.sim.world); len(flipped) > 0 {
+		c.events <- CellsFlipped{r.sim.Turn(), flipped}
+	}
+}
+
+// handle applies a command, acknowledges it, and reports whether the simulation should stop.
+// The error is an io failure that stops the run.
+func (r *runState) handle(c distributorChannels, request Request) (bool, error) {
+	turn := r.sim.Turn()
+	switch command := request.Command.(type) {
+	case Pause, togglePause:
+		return r.pause(c, request)
+	case Resume:
+		request.acknowledge(ErrNotPaused)
+	case Snapshot:
+		saveWorld(r.p, c, r.sim.World(), turn, request.Ack)
+	case Record:
+		err := r.recorder.toggle(r.p, c, r.sim)
+		request.acknowledge(err)
+		return false, err
+	case Quit, Kill:
+		request.acknowledge(nil)
+		return true, nil
+	case Step:
+		for i := 0; i < command.Turns && r.sim.Turn() < r.p.Turns; i++ {
+			r.advance(c)
+		}
+		request.acknowledge(nil)
+	case SetCell:
+		request.acknowledge(r.setCell(c, command))
+	case SetRule:
+		err := r.sim.SetRule(command.Rule)
+		if err == nil {
+			r.p.Rule = r.sim.Rule()
+			c.events <- RuleChange{turn, r.p.Rule}
+		}
+		request.acknowledge(err)
+	default:
+		request.acknowledge(fmt.Errorf("unknown command %v", command))
+	}
+	return false, nil
+}
+
+// pause sends StateChange Paused and keeps serving commands until execution is resumed or quit.
+func (r *runState) pause(c distributorChannels, paused Request) (bool, error) {
+	c.events <- StateChange{r.sim.Turn(), Paused}
+	paused.acknowledge(nil)
+	for {
+		select {
+		case err := <-c.ioFailures:
+			return false, err
+		case <-c.done:
+			r.cancelled = true
+			return true, nil
+		case <-r.view.changes():
+			r.moved(c)
+		case request, ok := <-r.requests:
+			if !ok {
+				// Nobody is left to resume execution.
+				return true, nil
+			}
+			switch request.Command.(type) {
+			case Resume, togglePause:
+				c.events <- StateChange{r.sim.Turn(), Executing}
+				request.acknowledge(nil)
+				return false, nil
+			case Pause:
+				request.acknowledge(ErrPaused)
+				continue
+			}
+			if quit, err := r.handle(c, request); quit || err != nil {
+				return quit, err
+			}
+		}
+	}
+}
+
+// setCell applies a SetCell command, flipping the cell if it is in view.
+func (r *runState) setCell(c distributorChannels, command SetCell) error {
+	cell := command.Cell
+	if cell.X < 0 || cell.Y < 0 || cell.X >= r.p.ImageWidth || cell.Y >= r.p.ImageHeight {
+		return fmt.Errorf("cell (%v, %v) is outside a %vx%v world", cell.X, cell.Y, r.p.ImageWidth, r.p.ImageHeight)
+	}
+	if (r.sim.world[cell.Y][cell.X] != 0) == command.Alive {
+		return nil
+	}
+	r.sim.SetCell(cell.X, cell.Y, command.Alive)
+	flipped := []util.Cell{cell}
+	r.stats.count(r.sim.world, flipped)
+	if flipped = r.view.flips(r.p, r.sim.world, flipped); len(flipped) > 0 {
+		c.events <- CellsFlipped{r.sim.Turn(), flipped}
+	}
+	return nil
 }
@@ -2,6 +2,7 @@ package gol
 
 import (
 	"fmt"
+	"image"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -70,6 +71,31 @@ type TurnComplete struct { // implements Event
 	CompletedTurns int
 }
 
+// `TurnStats` is an Event with statistics about the world after a turn.
+// It is only sent when Params.StatsInterval is set, every StatsInterval turns from turn 0,
+// just before the `TurnComplete` of its turn.
+type TurnStats struct { // implements Event
+	CompletedTurns int
+	// Births and Deaths count the cells that came alive or died since the previous TurnStats.
+	Births int
+	Deaths int
+	Alive  int
+	// Bounds is the smallest rectangle holding every alive cell, and is empty when none are.
+	Bounds image.Rectangle
+	// CentroidX and CentroidY are the mean position of the alive cells.
+	CentroidX float64
+	CentroidY float64
+	// Hash is a 64-bit FNV-1a hash of the world, so that repeated states can be found.
+	Hash uint64
+}
+
+// `RuleChange` is an Event notifying the user about the rule the simulation follows, in B/S notation.
+// This Event is sent before execution starts, and every time the rule is changed with SetRule.
+type RuleChange struct { // implements Event
+	CompletedTurns int
+	Rule           string
+}
+
 // `FinalTurnComplete` is an Event notifying the testing framework about the new world state after execution finished.
 // The data included with this Event is used directly by the tests.
 // SDL closes the window when this Event is sent.
@@ -78,6 +104,13 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// `ErrorEvent` is an Event notifying the user that the run failed, e.g. because an image could not be read or written.
+// The run stops after this Event is sent: a StateChange Quitting follows and the events channel is closed.
+type ErrorEvent struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -141,6 +174,22 @@ func (event TurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event TurnStats) String() string {
+	return fmt.Sprintf("Births %v Deaths %v Alive %v", event.Births, event.Deaths, event.Alive)
+}
+
+func (event TurnStats) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event RuleChange) String() string {
+	return fmt.Sprintf("Rule %v", event.Rule)
+}
+
+func (event RuleChange) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event FinalTurnComplete) String() string {
 	return "Final Turn Complete"
 }
@@ -149,6 +198,14 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event ErrorEvent) String() string {
+	return fmt.Sprintf("Error: %v", event.Err)
+}
+
+func (event ErrorEvent) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
@@ -1,37 +1,180 @@
 package gol
 
+import (
+	"context"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+
+	// InputFormat and OutputFormat select the image encoding used by the io goroutine.
+	// Either PgmFormat (the default when empty) or MacrocellFormat; PngFormat is output only.
+	// An empty InputFormat is taken from the extension of InputPath.
+	InputFormat  string
+	OutputFormat string
+
+	// InputPath overrides the image loaded at start-up, which is images/<w>x<h>.<format> by default.
+	// OutputDir is where images are written, out by default. OutputTemplate names them,
+	// replacing {w}, {h}, {turn}, {threads}, {rule} and {pid}; it defaults to DefaultOutputTemplate.
+	InputPath      string
+	OutputDir      string
+	OutputTemplate string
+
+	// GifInterval records every Nth completed turn into an animated GIF from the start of the run.
+	// When 0, recording is only started with the 'r' key, which captures every turn.
+	// GifScale is the size of a cell in pixels and GifDelay the frame delay in 100ths of a second.
+	// Recordings whose frames would take more than MaxGifBytes are split into several files.
+	GifInterval int
+	GifScale    int
+	GifDelay    int
+
+	// FrameInterval makes the front-end write every Nth completed turn of its window to numbered
+	// PNG frames in <OutputDir>/frames, with each cell FrameScale pixels wide. 0 disables it.
+	FrameInterval int
+	FrameScale    int
+
+	// KeyFile and KeyBindings remap the SDL window's keys to commands, such as "p=pause,x=quit".
+	// The file holds one or more bindings per line; KeyBindings is applied after it.
+	KeyFile     string
+	KeyBindings string
+
+	// Zoom is the size of a cell in the SDL window, in pixels; 0 picks one so that small boards
+	// fill at least 512 pixels. Gridlines are drawn between cells when Zoom is at least GridZoom,
+	// unless GridZoom is 0. ThemeFile and Palette set the colours, such as "alive=#ffd000".
+	Zoom      int
+	GridZoom  int
+	ThemeFile string
+	Palette   string
+
+	// Rule is the rule that Run and NewSimulation start with, in B/S notation such as B36/S23. It defaults to Rule.
+	Rule string
+
+	// StatsInterval sends a TurnStats event every StatsInterval turns. 0 disables them.
+	StatsInterval int
+}
+
+// Option configures a run with a handle that the caller keeps using while it runs,
+// which is why it isn't part of Params, a plain value that is copied freely.
+type Option func(*runOptions)
+
+type runOptions struct {
+	viewport *Viewport
+	metrics  *Metrics
+}
+
+// WithViewport limits flip events to the cells a front-end is watching.
+func WithViewport(viewport *Viewport) Option {
+	return func(o *runOptions) {
+		o.viewport = viewport
+	}
+}
+
+// WithMetrics collects the progress of the run for scraping.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *runOptions) {
+		o.metrics = metrics
+	}
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	CompletedTurns int
+	// World is the final world, indexed [y][x] with 255 for alive cells. It is nil if the image couldn't be loaded.
+	World [][]byte
+	Alive []util.Cell
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
-func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+func Run(p Params, events chan<- Event, keyPresses <-chan rune, options ...Option) {
+	RunContext(context.Background(), p, events, keyPresses, options...)
+}
 
-	//	TODO: Put the missing channels in here.
+// RunContext runs the Game of Life like Run until it finishes, fails, or ctx is cancelled,
+// which stops it as if 'q' had been pressed. It returns the final world and the error that
+// stopped the run: the one reported by an ErrorEvent, or ctx.Err() if it was cancelled.
+// A run that finishes before ctx is cancelled returns a nil error.
+// Like quitting, cancelling still writes the final image, and RunContext waits until it is written.
+// Events is closed exactly once, before RunContext returns. Once ctx is cancelled, events that
+// nobody is ready to receive are discarded, so RunContext returns even if nobody is receiving.
+func RunContext(ctx context.Context, p Params, events chan<- Event, keyPresses <-chan rune, options ...Option) (Result, error) {
+	done := make(chan struct{})
+	defer close(done)
+	return RunCommands(ctx, p, events, keyRequests(keyPresses, done), options...)
+}
 
+// RunCommands is RunContext controlled by typed commands instead of key presses.
+// Each request is acknowledged once its command has been applied.
+func RunCommands(ctx context.Context, p Params, events chan<- Event, requests <-chan Request, options ...Option) (Result, error) {
+	var o runOptions
+	for _, option := range options {
+		option(&o)
+	}
+	sent := make(chan Event)
+	forwarded := make(chan bool)
+	go forwardEvents(ctx, sent, events, forwarded)
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
+	ioErrors := make(chan error)
+	ioFilename := make(chan string)
+	ioRule := make(chan string)
+	ioOutput := make(chan []byte)
+	ioInput := make(chan uint8)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
 		idle:     ioIdle,
-		filename: nil,
-		output:   nil,
-		input:    nil,
+		errors:   ioErrors,
+		filename: ioFilename,
+		rule:     ioRule,
+		output:   ioOutput,
+		input:    ioInput,
 	}
 	go startIo(p, ioChannels)
 
 	distributorChannels := distributorChannels{
-		events:     events,
+		events:     sent,
 		ioCommand:  ioCommand,
 		ioIdle:     ioIdle,
-		ioFilename: nil,
-		ioOutput:   nil,
-		ioInput:    nil,
+		ioErrors:   ioErrors,
+		ioBusy:     make(chan struct{}, 1),
+		ioFailures: make(chan error, 1),
+		ioFilename: ioFilename,
+		ioRule:     ioRule,
+		ioOutput:   ioOutput,
+		ioInput:    ioInput,
+		requests:   requests,
+		metrics:    o.metrics,
+		viewport:   o.viewport,
+		done:       ctx.Done(),
+	}
+	o.metrics.start(events)
+	result, err := distributor(p, distributorChannels)
+	<-forwarded
+	if err == errCancelled {
+		err = ctx.Err()
+	}
+	return result, err
+}
+
+// forwardEvents sends the distributor's events on to the caller, then closes events and signals forwarded.
+// After ctx is cancelled, events the caller isn't ready for are discarded.
+func forwardEvents(ctx context.Context, in <-chan Event, events chan<- Event, forwarded chan<- bool) {
+	for event := range in {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			select {
+			case events <- event:
+			default:
+			}
+		}
 	}
-	distributor(p, distributorChannels)
+	close(events)
+	forwarded <- true
 }
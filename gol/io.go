@@ -1,19 +1,90 @@
 package gol
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// Image formats understood by the io goroutine.
+const (
+	PgmFormat       = "pgm"
+	MacrocellFormat = "mc"
+	PngFormat       = "png"
+)
+
+// gifFormat is the format of recordings, which are written as animated GIFs.
+const gifFormat = "gif"
+
+// Errors reported by the io goroutine when an image cannot be read.
+// They are wrapped with the path of the offending file, so use errors.Is to test for them.
+// Failures to open, read or write a file are reported as the underlying *os.PathError.
+var (
+	ErrBadMagic          = errors.New("not a recognised image file")
+	ErrDimensionMismatch = errors.New("image dimensions do not match the world")
+	ErrMaxval            = errors.New("unsupported maxval/bit depth")
+	ErrCorruptImage      = errors.New("corrupt image data")
+)
+
+// DefaultOutputTemplate names output images after the world size and the number of completed turns.
+const DefaultOutputTemplate = "{w}x{h}x{turn}"
+
+// MaxGifBytes is the most memory the frames of a recording take before they are written out.
+// Frames take a byte per pixel, so a longer recording is split into GIFs named after the turns each covers.
+const MaxGifBytes = 32 << 20
+
+// outputFilename expands p.OutputTemplate for a file of the given format and turn, or range of turns.
+// The placeholders {w}, {h}, {turn}, {threads}, {rule} and {pid} are replaced. The template is shared
+// by every format, so an image extension at its end, such as .pgm, is replaced by the format's.
+func outputFilename(p Params, format, turn string) string {
+	template := p.OutputTemplate
+	if template == "" {
+		template = DefaultOutputTemplate
+	}
+	filename := strings.NewReplacer(
+		"{w}", strconv.Itoa(p.ImageWidth),
+		"{h}", strconv.Itoa(p.ImageHeight),
+		"{turn}", turn,
+		"{threads}", strconv.Itoa(p.Threads),
+		"{rule}", strings.ReplaceAll(ruleName(p), "/", ""),
+		"{pid}", strconv.Itoa(os.Getpid()),
+	).Replace(template)
+	ext := filepath.Ext(filename)
+	for _, known := range []string{PgmFormat, MacrocellFormat, PngFormat, gifFormat} {
+		if strings.EqualFold(ext, "."+known) {
+			return strings.TrimSuffix(filename, ext) + "." + format
+		}
+	}
+	return filename
+}
+
+// outputFormat returns the format images are written in, which is PgmFormat unless p.OutputFormat is another.
+func outputFormat(p Params) string {
+	switch p.OutputFormat {
+	case MacrocellFormat, PngFormat:
+		return p.OutputFormat
+	}
+	return PgmFormat
+}
+
 type ioChannels struct {
 	command <-chan ioCommand
 	idle    chan<- bool
+	errors  chan<- error
 
 	filename <-chan string
-	output   <-chan uint8
+	rule     <-chan string
+	output   <-chan []byte
 	input    chan<- uint8
 }
 
@@ -21,6 +92,9 @@ type ioChannels struct {
 type ioState struct {
 	params   Params
 	channels ioChannels
+
+	// animation collects the frames of the GIF currently being recorded.
+	animation *gif.GIF
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -31,97 +105,497 @@ type ioCommand uint8
 //		ioOutput 	= 0
 //		ioInput 	= 1
 //		ioCheckIdle = 2
+//		ioRecordFrame = 3
+//		ioRecordFinish = 4
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioRecordFrame
+	ioRecordFinish
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
+// outputPath returns where the named image is written in the given format.
+// The format's extension is added unless the filename already ends with it.
+func (io *ioState) outputPath(filename, format string) string {
+	dir := io.params.OutputDir
+	if dir == "" {
+		dir = "out"
+	}
+	if !strings.HasSuffix(filename, "."+format) {
+		filename += "." + format
+	}
+	return filepath.Join(dir, filename)
+}
 
+// inputPath returns the image to load, either p.InputPath or the named image in images/.
+func (io *ioState) inputPath(filename, format string) string {
+	if io.params.InputPath != "" {
+		return io.params.InputPath
+	}
+	return filepath.Join("images", filename+"."+format)
+}
+
+// inputFormat returns p.InputFormat, falling back to the extension of p.InputPath.
+func (io *ioState) inputFormat() string {
+	if io.params.InputFormat != "" {
+		return io.params.InputFormat
+	}
+	return strings.TrimPrefix(filepath.Ext(io.params.InputPath), ".")
+}
+
+// discardImage receives the rows of an image without storing them, so that the distributor is never left blocked.
+func (io *ioState) discardImage() {
+	for y := 0; y < io.params.ImageHeight; y++ {
+		<-io.channels.output
+	}
+}
+
+// writePgmImage receives the rows of an image and writes them to a pgm file.
+// Rows are buffered and written as they arrive, so the io goroutine never holds the whole image.
+// The whole image is always received, even if the file cannot be written.
+func (io *ioState) writePgmImage() error {
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
+	path := io.outputPath(filename, PgmFormat)
+	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	file, ioError := os.Create(path)
+	if ioError != nil {
+		io.discardImage()
+		return ioError
+	}
 	defer file.Close()
 
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
-
-	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
-	}
+	writer := bufio.NewWriter(file)
+	//_, _ = writer.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
+	_, ioError = writer.WriteString("P5\n" +
+		strconv.Itoa(io.params.ImageWidth) + " " + strconv.Itoa(io.params.ImageHeight) + "\n" +
+		strconv.Itoa(255) + "\n")
 
 	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
+		row := <-io.channels.output
+		// Keep receiving after a failed write, but stop writing.
+		if ioError == nil {
+			_, ioError = writer.Write(row)
 		}
 	}
+	if ioError != nil {
+		return ioError
+	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
-		}
+	ioError = writer.Flush()
+	if ioError != nil {
+		return ioError
 	}
 
 	ioError = file.Sync()
-	util.Check(ioError)
+	if ioError != nil {
+		return ioError
+	}
 
 	fmt.Println("File", filename, "output done!")
+	return nil
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// readPgmImage opens a pgm file and returns its data as an array of bytes.
+func (io *ioState) readPgmImage() ([]byte, error) {
 
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	data, ioError := os.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+	path := io.inputPath(filename, PgmFormat)
+	data, ioError := os.ReadFile(path)
+	if ioError != nil {
+		return nil, ioError
+	}
 
 	fields := strings.Fields(string(data))
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	if len(fields) < 4 || fields[0] != "P5" {
+		return nil, fmt.Errorf("%v: %w", path, ErrBadMagic)
 	}
 
 	width, _ := strconv.Atoi(fields[1])
 	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+		return nil, fmt.Errorf("%v: %w: width %v, expected %v", path, ErrDimensionMismatch, width, io.params.ImageWidth)
 	}
 
 	height, _ := strconv.Atoi(fields[2])
 	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+		return nil, fmt.Errorf("%v: %w: height %v, expected %v", path, ErrDimensionMismatch, height, io.params.ImageHeight)
 	}
 
 	maxval, _ := strconv.Atoi(fields[3])
 	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+		return nil, fmt.Errorf("%v: %w: %v", path, ErrMaxval, fields[3])
+	}
+
+	var image []byte
+	if len(fields) > 4 {
+		image = []byte(fields[4])
+	}
+	if len(image) != width*height {
+		return nil, fmt.Errorf("%v: %w: %v bytes of pixel data, expected %v", path, ErrCorruptImage, len(image), width*height)
+	}
+
+	fmt.Println("File", filename, "input done!")
+	return image, nil
+}
+
+// writePngImage receives the rows of an image and writes it to a greyscale png file.
+func (io *ioState) writePngImage() error {
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+
+	img := image.NewGray(image.Rect(0, 0, io.params.ImageWidth, io.params.ImageHeight))
+	for y := 0; y < io.params.ImageHeight; y++ {
+		copy(img.Pix[y*img.Stride:], <-io.channels.output)
+	}
+
+	path := io.outputPath(filename, PngFormat)
+	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	file, ioError := os.Create(path)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
+
+	ioError = png.Encode(file, img)
+	if ioError != nil {
+		return ioError
+	}
+
+	ioError = file.Sync()
+	if ioError != nil {
+		return ioError
+	}
+
+	fmt.Println("File", filename, "output done!")
+	return nil
+}
+
+// recordGifFrame receives the rows of an image and appends it as a frame to the GIF being recorded.
+// Each cell is drawn as a GifScale x GifScale square.
+func (io *ioState) recordGifFrame() {
+	scale := io.params.GifScale
+	if scale < 1 {
+		scale = 1
+	}
+
+	frame := image.NewPaletted(
+		image.Rect(0, 0, io.params.ImageWidth*scale, io.params.ImageHeight*scale),
+		color.Palette{color.Black, color.White},
+	)
+	for y := 0; y < io.params.ImageHeight; y++ {
+		row := <-io.channels.output
+		for x := 0; x < io.params.ImageWidth; x++ {
+			if row[x] == 0 {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					frame.SetColorIndex(x*scale+dx, y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	if io.animation == nil {
+		io.animation = &gif.GIF{}
+	}
+	io.animation.Image = append(io.animation.Image, frame)
+	io.animation.Delay = append(io.animation.Delay, io.params.GifDelay)
+}
+
+// finishGif writes every recorded frame to an animated gif file and starts a new recording.
+func (io *ioState) finishGif() error {
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+
+	animation := io.animation
+	io.animation = nil
+	if animation == nil {
+		return nil
 	}
 
-	image := []byte(fields[4])
+	path := io.outputPath(filename, gifFormat)
+	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	file, ioError := os.Create(path)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
+
+	ioError = gif.EncodeAll(file, animation)
+	if ioError != nil {
+		return ioError
+	}
+
+	ioError = file.Sync()
+	if ioError != nil {
+		return ioError
+	}
+
+	fmt.Println("File", filename, "output done!")
+	return nil
+}
+
+// macrocellLeafLevel is the level of a Macrocell leaf node, which stores an 8x8 square of cells.
+const macrocellLeafLevel = 3
+
+// macrocellNode identifies a Macrocell quadtree node by its contents, so that identical
+// subtrees are only written once. Leaves store one bit per cell, row by row.
+type macrocellNode struct {
+	level          int
+	leaf           uint64
+	nw, ne, sw, se int
+}
+
+func (node macrocellNode) String() string {
+	if node.level > macrocellLeafLevel {
+		return fmt.Sprintf("%d %d %d %d %d", node.level, node.nw, node.ne, node.sw, node.se)
+	}
+
+	var rows []string
+	for y := 0; y < 8; y++ {
+		row := make([]byte, 8)
+		for x := 0; x < 8; x++ {
+			row[x] = '.'
+			if node.leaf&(1<<uint(y*8+x)) != 0 {
+				row[x] = '*'
+			}
+		}
+		rows = append(rows, strings.TrimRight(string(row), ".")+"$")
+	}
+	return strings.TrimRight(strings.Join(rows, ""), "$") + "$"
+}
+
+// macrocellTree deduplicates quadtree nodes, numbering them in the order they are first seen.
+type macrocellTree struct {
+	indices map[macrocellNode]int
+	nodes   []macrocellNode
+}
+
+// add returns the index of the given node, appending it to the tree if it has not been seen yet.
+// Empty nodes are always 0 and are never written.
+func (tree *macrocellTree) add(node macrocellNode) int {
+	if node == (macrocellNode{level: node.level}) {
+		return 0
+	}
+	if index, ok := tree.indices[node]; ok {
+		return index
+	}
+	tree.nodes = append(tree.nodes, node)
+	tree.indices[node] = len(tree.nodes)
+	return len(tree.nodes)
+}
+
+// sortedCells returns the keys of a quadtree level in row-major order, so output is deterministic.
+func sortedCells(level map[util.Cell]int) []util.Cell {
+	cells := make([]util.Cell, 0, len(level))
+	for cell := range level {
+		cells = append(cells, cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+// writeMacrocellImage receives the rows of an image and writes it to a Macrocell (.mc) file that follows rule.
+// The world is stored as a deduplicated quadtree whose top-left corner is cell (0, 0),
+// so large or sparse worlds only cost as much as their distinct non-empty 8x8 blocks.
+func (io *ioState) writeMacrocellImage(rule string) error {
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+
+	leaves := make(map[util.Cell]uint64)
+	for y := 0; y < io.params.ImageHeight; y++ {
+		row := <-io.channels.output
+		for x := 0; x < io.params.ImageWidth; x++ {
+			if row[x] != 0 {
+				leaves[util.Cell{X: x / 8, Y: y / 8}] |= 1 << uint((y%8)*8+x%8)
+			}
+		}
+	}
+
+	tree := macrocellTree{indices: make(map[macrocellNode]int)}
+	level := make(map[util.Cell]int)
+	for cell := range leaves {
+		level[cell] = 0
+	}
+	for _, cell := range sortedCells(level) {
+		level[cell] = tree.add(macrocellNode{level: macrocellLeafLevel, leaf: leaves[cell]})
+	}
+
+	depth := macrocellLeafLevel
+	for size := 8; size < io.params.ImageWidth || size < io.params.ImageHeight; size *= 2 {
+		depth++
+		children := make(map[util.Cell]*macrocellNode)
+		parents := make(map[util.Cell]int)
+		for _, cell := range sortedCells(level) {
+			parent := util.Cell{X: cell.X / 2, Y: cell.Y / 2}
+			node, ok := children[parent]
+			if !ok {
+				node = &macrocellNode{level: depth}
+				children[parent] = node
+			}
+			switch (cell.Y%2)*2 + cell.X%2 {
+			case 0:
+				node.nw = level[cell]
+			case 1:
+				node.ne = level[cell]
+			case 2:
+				node.sw = level[cell]
+			case 3:
+				node.se = level[cell]
+			}
+			parents[parent] = 0
+		}
+		for _, parent := range sortedCells(parents) {
+			parents[parent] = tree.add(*children[parent])
+		}
+		level = parents
+	}
+
+	if level[util.Cell{}] == 0 {
+		// An empty world still needs a root so that readers know its size.
+		tree.nodes = append(tree.nodes, macrocellNode{level: depth})
+	}
+
+	path := io.outputPath(filename, MacrocellFormat)
+	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	file, ioError := os.Create(path)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
 
-	for _, b := range image {
-		io.channels.input <- b
+	writer := bufio.NewWriter(file)
+	_, ioError = writer.WriteString("[M2] (gol-skeleton)\n#R " + rule + "\n")
+	if ioError != nil {
+		return ioError
+	}
+	for _, node := range tree.nodes {
+		_, ioError = writer.WriteString(node.String() + "\n")
+		if ioError != nil {
+			return ioError
+		}
+	}
+
+	ioError = writer.Flush()
+	if ioError != nil {
+		return ioError
+	}
+
+	ioError = file.Sync()
+	if ioError != nil {
+		return ioError
+	}
+
+	fmt.Println("File", filename, "output done!")
+	return nil
+}
+
+// readMacrocellImage opens a Macrocell (.mc) file and returns its data as an array of bytes.
+// The top-left corner of the root node is placed at cell (0, 0).
+func (io *ioState) readMacrocellImage() ([]byte, error) {
+
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+
+	path := io.inputPath(filename, MacrocellFormat)
+	data, ioError := os.ReadFile(path)
+	if ioError != nil {
+		return nil, ioError
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r", ""), "\n")
+	if !strings.HasPrefix(lines[0], "[M2]") {
+		return nil, fmt.Errorf("%v: %w", path, ErrBadMagic)
+	}
+
+	// Node 0 is the empty node of any level.
+	nodes := []macrocellNode{{}}
+	for _, line := range lines[1:] {
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '.' || line[0] == '*' || line[0] == '$':
+			node := macrocellNode{level: macrocellLeafLevel}
+			x, y := 0, 0
+			for _, c := range line {
+				switch c {
+				case '.':
+					x++
+				case '*':
+					if x >= 8 || y >= 8 {
+						return nil, fmt.Errorf("%v: %w: leaf %q", path, ErrCorruptImage, line)
+					}
+					node.leaf |= 1 << uint(y*8+x)
+					x++
+				case '$':
+					x, y = 0, y+1
+				}
+			}
+			nodes = append(nodes, node)
+		default:
+			var node macrocellNode
+			_, err := fmt.Sscan(line, &node.level, &node.nw, &node.ne, &node.sw, &node.se)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w: node %q", path, ErrCorruptImage, line)
+			}
+			for _, child := range []int{node.nw, node.ne, node.sw, node.se} {
+				if child < 0 || child >= len(nodes) || (child != 0 && nodes[child].level != node.level-1) {
+					return nil, fmt.Errorf("%v: %w: node %q", path, ErrCorruptImage, line)
+				}
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 1 {
+		return nil, fmt.Errorf("%v: %w: no nodes", path, ErrCorruptImage)
+	}
+
+	pixels := make([]byte, io.params.ImageWidth*io.params.ImageHeight)
+
+	var paint func(index, x0, y0 int) error
+	paint = func(index, x0, y0 int) error {
+		if index == 0 {
+			return nil
+		}
+		node := nodes[index]
+		if node.level == macrocellLeafLevel {
+			for i := 0; i < 64; i++ {
+				if node.leaf&(1<<uint(i)) == 0 {
+					continue
+				}
+				x, y := x0+i%8, y0+i/8
+				if x >= io.params.ImageWidth || y >= io.params.ImageHeight {
+					return fmt.Errorf("%v: %w: alive cell at (%v, %v)", path, ErrDimensionMismatch, x, y)
+				}
+				pixels[y*io.params.ImageWidth+x] = 255
+			}
+			return nil
+		}
+		half := 1 << uint(node.level-1)
+		for i, child := range []int{node.nw, node.ne, node.sw, node.se} {
+			if err := paint(child, x0+(i%2)*half, y0+(i/2)*half); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := paint(len(nodes)-1, 0, 0); err != nil {
+		return nil, err
 	}
 
 	fmt.Println("File", filename, "input done!")
+	return pixels, nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -135,11 +609,35 @@ func startIo(p Params, c ioChannels) {
 		// Block and wait for requests from the distributor
 		switch command {
 		case ioInput:
-			io.readPgmImage()
+			var pixels []byte
+			var err error
+			if io.inputFormat() == MacrocellFormat {
+				pixels, err = io.readMacrocellImage()
+			} else {
+				pixels, err = io.readPgmImage()
+			}
+			// The distributor only waits for the image if it could be read.
+			io.channels.errors <- err
+			for _, b := range pixels {
+				io.channels.input <- b
+			}
 		case ioOutput:
-			io.writePgmImage()
+			// The rule can change during a run, so it is sent with every image.
+			rule := <-io.channels.rule
+			switch io.params.OutputFormat {
+			case MacrocellFormat:
+				io.channels.errors <- io.writeMacrocellImage(rule)
+			case PngFormat:
+				io.channels.errors <- io.writePngImage()
+			default:
+				io.channels.errors <- io.writePgmImage()
+			}
 		case ioCheckIdle:
 			io.channels.idle <- true
+		case ioRecordFrame:
+			io.recordGifFrame()
+		case ioRecordFinish:
+			io.channels.errors <- io.finishGif()
 		}
 	}
 }
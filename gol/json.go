@@ -0,0 +1,310 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// taggedEvent is the JSON form of every Event: its type, its completed turns and a payload with
+// the rest of its fields, e.g. {"type":"AliveCellsCount","completed_turns":10,"payload":{"cells_count":5}}
+// Recorded events also hold the microseconds since the recording started, so that they can be replayed.
+type taggedEvent struct {
+	Type           string          `json:"type"`
+	CompletedTurns int             `json:"completed_turns"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Elapsed        int64           `json:"elapsed_us,omitempty"`
+}
+
+type aliveCellsCountPayload struct {
+	CellsCount int `json:"cells_count"`
+}
+
+type imageOutputCompletePayload struct {
+	Filename string `json:"filename"`
+}
+
+type stateChangePayload struct {
+	NewState State `json:"new_state"`
+}
+
+type cellFlippedPayload struct {
+	Cell util.Cell `json:"cell"`
+}
+
+type cellsFlippedPayload struct {
+	Cells []util.Cell `json:"cells"`
+}
+
+type turnStatsPayload struct {
+	Births    int        `json:"births"`
+	Deaths    int        `json:"deaths"`
+	Alive     int        `json:"alive"`
+	Bounds    boundsJSON `json:"bounds"`
+	CentroidX float64    `json:"centroid_x"`
+	CentroidY float64    `json:"centroid_y"`
+	Hash      string     `json:"hash"`
+}
+
+// boundsJSON is a rectangle of cells, from min inclusive to max exclusive.
+type boundsJSON struct {
+	MinX int `json:"min_x"`
+	MinY int `json:"min_y"`
+	MaxX int `json:"max_x"`
+	MaxY int `json:"max_y"`
+}
+
+type ruleChangePayload struct {
+	Rule string `json:"rule"`
+}
+
+type finalTurnCompletePayload struct {
+	Alive []util.Cell `json:"alive"`
+}
+
+type errorEventPayload struct {
+	Error string `json:"error"`
+}
+
+func marshalEvent(kind string, completedTurns int, payload interface{}) ([]byte, error) {
+	tagged := taggedEvent{Type: kind, CompletedTurns: completedTurns}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		tagged.Payload = data
+	}
+	return json.Marshal(tagged)
+}
+
+func (event AliveCellsCount) MarshalJSON() ([]byte, error) {
+	return marshalEvent("AliveCellsCount", event.CompletedTurns, aliveCellsCountPayload{event.CellsCount})
+}
+
+func (event ImageOutputComplete) MarshalJSON() ([]byte, error) {
+	return marshalEvent("ImageOutputComplete", event.CompletedTurns, imageOutputCompletePayload{event.Filename})
+}
+
+func (event StateChange) MarshalJSON() ([]byte, error) {
+	return marshalEvent("StateChange", event.CompletedTurns, stateChangePayload{event.NewState})
+}
+
+func (event CellFlipped) MarshalJSON() ([]byte, error) {
+	return marshalEvent("CellFlipped", event.CompletedTurns, cellFlippedPayload{event.Cell})
+}
+
+func (event CellsFlipped) MarshalJSON() ([]byte, error) {
+	return marshalEvent("CellsFlipped", event.CompletedTurns, cellsFlippedPayload{event.Cells})
+}
+
+func (event TurnComplete) MarshalJSON() ([]byte, error) {
+	return marshalEvent("TurnComplete", event.CompletedTurns, nil)
+}
+
+// MarshalJSON writes the hash in hexadecimal, as 64-bit integers lose precision in many JSON readers.
+func (event TurnStats) MarshalJSON() ([]byte, error) {
+	bounds := boundsJSON{event.Bounds.Min.X, event.Bounds.Min.Y, event.Bounds.Max.X, event.Bounds.Max.Y}
+	hash := fmt.Sprintf("%016x", event.Hash)
+	return marshalEvent("TurnStats", event.CompletedTurns, turnStatsPayload{event.Births, event.Deaths, event.Alive, bounds, event.CentroidX, event.CentroidY, hash})
+}
+
+func (event RuleChange) MarshalJSON() ([]byte, error) {
+	return marshalEvent("RuleChange", event.CompletedTurns, ruleChangePayload{event.Rule})
+}
+
+func (event FinalTurnComplete) MarshalJSON() ([]byte, error) {
+	return marshalEvent("FinalTurnComplete", event.CompletedTurns, finalTurnCompletePayload{event.Alive})
+}
+
+func (event ErrorEvent) MarshalJSON() ([]byte, error) {
+	message := ""
+	if event.Err != nil {
+		message = event.Err.Error()
+	}
+	return marshalEvent("ErrorEvent", event.CompletedTurns, errorEventPayload{message})
+}
+
+// MarshalJSON writes a State by name, e.g. "Paused".
+func (state State) MarshalJSON() ([]byte, error) {
+	if state < Paused || state > Quitting {
+		return nil, fmt.Errorf("cannot marshal state %d", int(state))
+	}
+	return json.Marshal(state.String())
+}
+
+func (state *State) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for s := Paused; s <= Quitting; s++ {
+		if s.String() == name {
+			*state = s
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown state %q", name)
+}
+
+// DecodeEvent reconstructs a typed Event from its JSON form.
+// ErrorEvents are decoded with an error that only has the original message.
+func DecodeEvent(data []byte) (Event, error) {
+	var tagged taggedEvent
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return nil, err
+	}
+	return tagged.decode()
+}
+
+func (tagged taggedEvent) decode() (Event, error) {
+	payload := func(v interface{}) error {
+		if len(tagged.Payload) == 0 {
+			return fmt.Errorf("%v event has no payload", tagged.Type)
+		}
+		return json.Unmarshal(tagged.Payload, v)
+	}
+
+	turns := tagged.CompletedTurns
+	switch tagged.Type {
+	case "AliveCellsCount":
+		var p aliveCellsCountPayload
+		err := payload(&p)
+		return AliveCellsCount{turns, p.CellsCount}, err
+	case "ImageOutputComplete":
+		var p imageOutputCompletePayload
+		err := payload(&p)
+		return ImageOutputComplete{turns, p.Filename}, err
+	case "StateChange":
+		var p stateChangePayload
+		err := payload(&p)
+		return StateChange{turns, p.NewState}, err
+	case "CellFlipped":
+		var p cellFlippedPayload
+		err := payload(&p)
+		return CellFlipped{turns, p.Cell}, err
+	case "CellsFlipped":
+		var p cellsFlippedPayload
+		err := payload(&p)
+		return CellsFlipped{turns, p.Cells}, err
+	case "TurnComplete":
+		return TurnComplete{turns}, nil
+	case "TurnStats":
+		var p turnStatsPayload
+		if err := payload(&p); err != nil {
+			return nil, err
+		}
+		hash, err := strconv.ParseUint(p.Hash, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("TurnStats hash %q: %w", p.Hash, err)
+		}
+		bounds := image.Rect(p.Bounds.MinX, p.Bounds.MinY, p.Bounds.MaxX, p.Bounds.MaxY)
+		return TurnStats{turns, p.Births, p.Deaths, p.Alive, bounds, p.CentroidX, p.CentroidY, hash}, nil
+	case "RuleChange":
+		var p ruleChangePayload
+		err := payload(&p)
+		return RuleChange{turns, p.Rule}, err
+	case "FinalTurnComplete":
+		var p finalTurnCompletePayload
+		err := payload(&p)
+		return FinalTurnComplete{turns, p.Alive}, err
+	case "ErrorEvent":
+		var p errorEventPayload
+		err := payload(&p)
+		return ErrorEvent{turns, errors.New(p.Error)}, err
+	default:
+		return nil, fmt.Errorf("unknown event type %q", tagged.Type)
+	}
+}
+
+// EventEncoder writes events as newline-delimited JSON, timed from when the encoder was created.
+type EventEncoder struct {
+	w     io.Writer
+	start time.Time
+}
+
+func NewEventEncoder(w io.Writer) *EventEncoder {
+	return &EventEncoder{w, time.Now()}
+}
+
+// Encode writes a single event on its own line.
+// The event is only marshalled once, as a large CellsFlipped is costly to encode: the time
+// is added to the end of its tagged form rather than decoding and encoding it again.
+func (e *EventEncoder) Encode(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(data) < 2 || data[0] != '{' || data[len(data)-1] != '}' {
+		return fmt.Errorf("%T event does not marshal to a JSON object", event)
+	}
+	elapsed := fmt.Sprintf(`,"elapsed_us":%d}`+"\n", time.Since(e.start).Microseconds())
+	_, err = e.w.Write(append(data[:len(data)-1], elapsed...))
+	return err
+}
+
+// EventDecoder reads events written by an EventEncoder.
+type EventDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewEventDecoder(r io.Reader) *EventDecoder {
+	scanner := bufio.NewScanner(r)
+	// A CellsFlipped or FinalTurnComplete line holds every cell of a large world.
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	return &EventDecoder{scanner: scanner}
+}
+
+// Decode returns the next event, or io.EOF once there are no more. Blank lines are skipped.
+func (d *EventDecoder) Decode() (Event, error) {
+	event, _, err := d.DecodeTimed()
+	return event, err
+}
+
+// DecodeTimed is Decode that also returns how long after the start of the recording the event was sent.
+// Events recorded without a time are returned with 0.
+func (d *EventDecoder) DecodeTimed() (Event, time.Duration, error) {
+	for d.scanner.Scan() {
+		d.line++
+		if len(d.scanner.Bytes()) == 0 {
+			continue
+		}
+		var tagged taggedEvent
+		err := json.Unmarshal(d.scanner.Bytes(), &tagged)
+		var event Event
+		if err == nil {
+			event, err = tagged.decode()
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %v: %w", d.line, err)
+		}
+		return event, time.Duration(tagged.Elapsed) * time.Microsecond, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, io.EOF
+}
+
+// TeeEvents forwards every event from in to out, writing each one to w as a line of JSON on the way.
+// out is closed once in is. A write error stops the recording but not the forwarding, and is returned.
+func TeeEvents(w io.Writer, in <-chan Event, out chan<- Event) error {
+	defer close(out)
+	encoder := NewEventEncoder(w)
+	var err error
+	for event := range in {
+		if err == nil {
+			err = encoder.Encode(event)
+		}
+		out <- event
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package gol
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Metrics collects the progress of a run for scraping. Pass one to Run with WithMetrics,
+// then serve what WriteTo writes, e.g. at /metrics, to expose the Prometheus text format.
+type Metrics struct {
+	mu       sync.Mutex
+	turns    int
+	alive    int
+	avgTurns *util.AvgTurns
+	// turnsPerSec is sampled from avgTurns at most every metricsSampleInterval, as turns complete.
+	turnsPerSec int
+	sampled     time.Time
+	workers     []workerMetrics
+	ioBusy      time.Duration
+	ioAcquired  time.Time
+	events      chan<- Event
+	// subscription, when set by Watch, is reported instead of events.
+	subscription *Subscription
+}
+
+// workerMetrics sums the time a worker spent on its rows of each turn.
+type workerMetrics struct {
+	seconds float64
+	steps   int
+}
+
+// metricsSampleInterval is how often the turns completed per second are sampled.
+const metricsSampleInterval = time.Second
+
+func NewMetrics() *Metrics {
+	return &Metrics{avgTurns: util.NewAvgTurns(), sampled: time.Now()}
+}
+
+// Watch reports the backlog of a subscription, such as the front-end's, instead of the events channel given to Run.
+// That channel is always close to empty when a Bus forwards it, as Forward receives events as soon as they are sent.
+func (m *Metrics) Watch(s *Subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscription = s
+}
+
+// The methods below are called by the distributor and do nothing on a nil *Metrics,
+// which is what a run has unless it was given WithMetrics.
+
+// start records the events channel, so that its depth can be reported.
+func (m *Metrics) start(events chan<- Event) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = events
+}
+
+// loaded records the alive cells of the initial world.
+func (m *Metrics) loaded(alive int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alive = alive
+}
+
+// step records how long each worker took to compute the next world, and how many cells it left alive.
+func (m *Metrics) step(results []workerResult) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alive = 0
+	for _, result := range results {
+		for len(m.workers) <= result.worker {
+			m.workers = append(m.workers, workerMetrics{})
+		}
+		m.workers[result.worker].seconds += result.duration.Seconds()
+		m.workers[result.worker].steps++
+		m.alive += result.alive
+	}
+}
+
+// completed records the number of completed turns, and samples how many complete per second.
+func (m *Metrics) completed(turn int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turns = turn
+	if time.Since(m.sampled) >= metricsSampleInterval {
+		m.turnsPerSec = m.avgTurns.Get(turn)
+		m.sampled = time.Now()
+	}
+}
+
+// ioAcquire and ioRelease time the conversations with the io goroutine, which never overlap.
+// Only the first ioRelease after an ioAcquire counts, so it can be called as soon as the io goroutine is done.
+func (m *Metrics) ioAcquire() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ioAcquired = time.Now()
+}
+
+func (m *Metrics) ioRelease() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.ioAcquired.IsZero() {
+		m.ioBusy += time.Since(m.ioAcquired)
+		m.ioAcquired = time.Time{}
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	turns, alive, turnsPerSec, ioBusy := m.turns, m.alive, m.turnsPerSec, m.ioBusy
+	workers := append([]workerMetrics(nil), m.workers...)
+	depth, capacity := len(m.events), cap(m.events)
+	subscription := m.subscription
+	m.mu.Unlock()
+	if subscription != nil {
+		depth, capacity = subscription.backlog()
+	}
+
+	metrics := &metricsWriter{w: w}
+	metrics.header("gol_turns_completed_total", "counter", "Number of completed turns.")
+	metrics.sample("gol_turns_completed_total", "", turns)
+	metrics.header("gol_alive_cells", "gauge", "Number of alive cells after the last completed turn.")
+	metrics.sample("gol_alive_cells", "", alive)
+	metrics.header("gol_turns_per_second", "gauge", "Average turns completed per second over the last few seconds of execution.")
+	metrics.sample("gol_turns_per_second", "", turnsPerSec)
+	metrics.header("gol_worker_step_seconds", "summary", "Time each worker spent computing its rows of a turn.")
+	for i, worker := range workers {
+		label := fmt.Sprintf(`{worker="%v"}`, i)
+		metrics.sample("gol_worker_step_seconds_sum", label, worker.seconds)
+		metrics.sample("gol_worker_step_seconds_count", label, worker.steps)
+	}
+	metrics.header("gol_event_channel_depth", "gauge", "Number of events waiting for the front-end.")
+	metrics.sample("gol_event_channel_depth", "", depth)
+	metrics.header("gol_event_channel_capacity", "gauge", "Number of events that can wait for the front-end, or 0 for any number.")
+	metrics.sample("gol_event_channel_capacity", "", capacity)
+	metrics.header("gol_io_busy_seconds_total", "counter", "Time the io goroutine spent loading and writing images.")
+	metrics.sample("gol_io_busy_seconds_total", "", ioBusy.Seconds())
+	return metrics.n, metrics.err
+}
+
+// metricsWriter writes exposition lines, keeping the first error.
+type metricsWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (mw *metricsWriter) printf(format string, a ...interface{}) {
+	if mw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(mw.w, format, a...)
+	mw.n += int64(n)
+	mw.err = err
+}
+
+func (mw *metricsWriter) header(name, kind, help string) {
+	mw.printf("# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+func (mw *metricsWriter) sample(name, labels string, value interface{}) {
+	mw.printf("%v%v %v\n", name, labels, value)
+}
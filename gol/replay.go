@@ -0,0 +1,147 @@
+package gol
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// ReplayParams selects the event log to replay and how to play it back.
+type ReplayParams struct {
+	// Path is a log recorded with -events-out.
+	Path string
+	// Speed is a multiple of the original speed, so 1 replays in real time and 10 ten times faster.
+	// When 0, or if the log wasn't timed, events are replayed as fast as the front-end takes them.
+	Speed float64
+	// Seek skips to the given turn: earlier events are replayed without delay.
+	Seek int
+}
+
+// Replay sends the events of a recorded run in place of Run, so the log can be shown by any front-end.
+// Like Run, it reports failures as an ErrorEvent, then sends StateChange Quitting and closes events.
+// The 'p' key pauses the replay and 'q' or 'k' stop it.
+func Replay(p Params, r ReplayParams, events chan<- Event, keyPresses <-chan rune) {
+	turn, err := replay(p, r, events, keyPresses)
+	if err != nil {
+		events <- ErrorEvent{turn, err}
+	}
+	events <- StateChange{turn, Quitting}
+	close(events)
+}
+
+// replayClock decides when each event is due, allowing for time spent paused.
+type replayClock struct {
+	speed   float64
+	start   time.Time
+	offset  time.Duration
+	started bool
+}
+
+// due returns when an event recorded at elapsed should be sent.
+func (c *replayClock) due(elapsed time.Duration) time.Time {
+	if !c.started {
+		c.start = time.Now()
+		c.offset = elapsed
+		c.started = true
+	}
+	return c.start.Add(time.Duration(float64(elapsed-c.offset) / c.speed))
+}
+
+func replay(p Params, r ReplayParams, events chan<- Event, keyPresses <-chan rune) (int, error) {
+	turn := 0
+	file, err := os.Open(r.Path)
+	if err != nil {
+		return turn, err
+	}
+	defer file.Close()
+
+	decoder := NewEventDecoder(file)
+	clock := replayClock{speed: r.Speed}
+	for {
+		event, elapsed, err := decoder.DecodeTimed()
+		if err == io.EOF {
+			return turn, nil
+		}
+		if err != nil {
+			return turn, fmt.Errorf("%v: %w", r.Path, err)
+		}
+		if err := checkReplayedCells(p, event); err != nil {
+			return turn, fmt.Errorf("%v: %w", r.Path, err)
+		}
+		if e, ok := event.(StateChange); ok && e.NewState == Quitting {
+			return e.CompletedTurns, nil
+		}
+
+		wait := time.Duration(0)
+		if r.Speed > 0 && event.GetCompletedTurns() >= r.Seek {
+			wait = time.Until(clock.due(elapsed))
+		}
+		timer := time.NewTimer(wait)
+		quit, pausedFor := awaitReplay(turn, timer.C, events, keyPresses)
+		timer.Stop()
+		if quit {
+			return turn, nil
+		}
+		clock.start = clock.start.Add(pausedFor)
+
+		events <- event
+		turn = event.GetCompletedTurns()
+	}
+}
+
+// awaitReplay waits until the next event is due, serving key presses in the meantime.
+// It reports whether the replay should stop, and how long it was paused for.
+func awaitReplay(turn int, due <-chan time.Time, events chan<- Event, keyPresses <-chan rune) (bool, time.Duration) {
+	var pausedFor time.Duration
+	for {
+		select {
+		case <-due:
+			return false, pausedFor
+		case key, ok := <-keyPresses:
+			if !ok {
+				// Nobody is left to press keys, so carry on until the event is due.
+				keyPresses = nil
+				continue
+			}
+			switch key {
+			case 'q', 'k':
+				return true, pausedFor
+			case 'p':
+				paused := time.Now()
+				events <- StateChange{turn, Paused}
+				for key = range keyPresses {
+					if key == 'p' || key == 'q' || key == 'k' {
+						break
+					}
+				}
+				if key != 'p' {
+					return true, pausedFor
+				}
+				events <- StateChange{turn, Executing}
+				pausedFor += time.Since(paused)
+			}
+		}
+	}
+}
+
+// checkReplayedCells makes sure that a replayed event only refers to cells within the world.
+func checkReplayedCells(p Params, event Event) error {
+	var cells []util.Cell
+	switch e := event.(type) {
+	case CellFlipped:
+		cells = []util.Cell{e.Cell}
+	case CellsFlipped:
+		cells = e.Cells
+	case FinalTurnComplete:
+		cells = e.Alive
+	}
+	for _, cell := range cells {
+		if cell.X < 0 || cell.Y < 0 || cell.X >= p.ImageWidth || cell.Y >= p.ImageHeight {
+			return fmt.Errorf("%w: cell (%v, %v) is outside a %vx%v world", ErrDimensionMismatch, cell.X, cell.Y, p.ImageWidth, p.ImageHeight)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package gol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ruleset is a life-like rule: a dead cell with n alive neighbours comes alive if birth[n],
+// and an alive one survives if survive[n].
+type ruleset struct {
+	name    string
+	birth   [9]bool
+	survive [9]bool
+}
+
+// parseRule reads a rule in B/S notation, such as B3/S23 or b36/s23.
+func parseRule(rule string) (ruleset, error) {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(rule)), "/")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "B") || !strings.HasPrefix(parts[1], "S") {
+		return ruleset{}, fmt.Errorf("rule %q is not in B/S notation, such as %v", rule, Rule)
+	}
+	r := ruleset{name: parts[0] + "/" + parts[1]}
+	for i, counts := range []*[9]bool{&r.birth, &r.survive} {
+		for _, digit := range parts[i][1:] {
+			if digit < '0' || digit > '8' {
+				return ruleset{}, fmt.Errorf("rule %q has %q where a neighbour count from 0 to 8 should be", rule, digit)
+			}
+			counts[digit-'0'] = true
+		}
+	}
+	return r, nil
+}
+
+// ruleName returns the rule a run starts with.
+func ruleName(p Params) string {
+	if p.Rule == "" {
+		return Rule
+	}
+	return p.Rule
+}
@@ -0,0 +1,118 @@
+package gol
+
+import (
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Simulation is a Game of Life world that is stepped synchronously, for use from Go code and tests
+// without channels or key presses. Run drives one between its events.
+// Worlds are copy-on-write: World and Snapshot never see later changes, so they can be kept.
+// A Simulation is not safe for concurrent use.
+type Simulation struct {
+	p     Params
+	rule  ruleset
+	world [][]byte
+	turn  int
+	// shared is set once the world has been handed out, so that SetCell must copy it first.
+	shared bool
+	// metrics, which Run sets, times the workers of each step.
+	metrics *Metrics
+}
+
+// NewSimulation returns an empty world of p.ImageWidth by p.ImageHeight cells, stepped by p.Threads workers.
+// It follows p.Rule, or the Game of Life if it is empty, and fails if p.Rule isn't in B/S notation.
+func NewSimulation(p Params) (*Simulation, error) {
+	rule, err := parseRule(ruleName(p))
+	if err != nil {
+		return nil, err
+	}
+	return &Simulation{p: p, rule: rule, world: makeWorld(p.ImageHeight, p.ImageWidth)}, nil
+}
+
+// newSimulationFromWorld starts a simulation following rule from a world that the caller may keep using.
+func newSimulationFromWorld(p Params, rule ruleset, world [][]byte) *Simulation {
+	return &Simulation{p: p, rule: rule, world: world, shared: true}
+}
+
+// Turn returns the number of turns completed.
+func (s *Simulation) Turn() int {
+	return s.turn
+}
+
+// Step completes n turns.
+func (s *Simulation) Step(n int) {
+	for i := 0; i < n; i++ {
+		s.step()
+	}
+}
+
+// step completes a turn and returns the cells that flipped.
+func (s *Simulation) step() []util.Cell {
+	world, flipped := calculateNextState(s.p, s.world, &s.rule, s.metrics)
+	s.world = world
+	s.shared = false
+	s.turn++
+	return flipped
+}
+
+// Rule returns the rule followed by the simulation, in B/S notation.
+func (s *Simulation) Rule() string {
+	return s.rule.name
+}
+
+// SetRule changes the rule followed from the next step, such as "B36/S23" for HighLife.
+func (s *Simulation) SetRule(rule string) error {
+	r, err := parseRule(rule)
+	if err != nil {
+		return err
+	}
+	s.rule = r
+	return nil
+}
+
+// World returns the current world, indexed [y][x] with 255 for alive cells.
+// It must not be modified, and doesn't change as the simulation goes on.
+func (s *Simulation) World() [][]byte {
+	s.shared = true
+	return s.world
+}
+
+// Alive returns every alive cell.
+func (s *Simulation) Alive() []util.Cell {
+	return calculateAliveCells(s.world)
+}
+
+// SetCell brings the cell at (x, y) alive or kills it. It panics if the cell is outside the world.
+func (s *Simulation) SetCell(x, y int, alive bool) {
+	if x < 0 || y < 0 || x >= s.p.ImageWidth || y >= s.p.ImageHeight {
+		panic(fmt.Sprintf("cell (%v, %v) is outside a %vx%v world", x, y, s.p.ImageWidth, s.p.ImageHeight))
+	}
+	if s.shared {
+		world := makeWorld(s.p.ImageHeight, s.p.ImageWidth)
+		for y, row := range s.world {
+			copy(world[y], row)
+		}
+		s.world = world
+		s.shared = false
+	}
+	if alive {
+		s.world[y][x] = 255
+	} else {
+		s.world[y][x] = 0
+	}
+}
+
+// Clear kills every cell. The number of completed turns is kept.
+func (s *Simulation) Clear() {
+	s.world = makeWorld(s.p.ImageHeight, s.p.ImageWidth)
+	s.shared = false
+}
+
+// Snapshot returns an independent copy of the simulation, which can be stepped or changed separately.
+func (s *Simulation) Snapshot() *Simulation {
+	s.shared = true
+	snapshot := *s
+	return &snapshot
+}
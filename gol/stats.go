@@ -0,0 +1,69 @@
+package gol
+
+import (
+	"hash/fnv"
+	"image"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// statsCounter counts births and deaths between TurnStats events.
+// A nil statsCounter, used when Params.StatsInterval is 0, never sends any.
+type statsCounter struct {
+	interval int
+	births   int
+	deaths   int
+}
+
+func newStatsCounter(p Params) *statsCounter {
+	if p.StatsInterval <= 0 {
+		return nil
+	}
+	return &statsCounter{interval: p.StatsInterval}
+}
+
+// count adds up the births and deaths among the cells flipped to reach world.
+func (s *statsCounter) count(world [][]byte, flipped []util.Cell) {
+	if s == nil {
+		return
+	}
+	for _, cell := range flipped {
+		if world[cell.Y][cell.X] != 0 {
+			s.births++
+		} else {
+			s.deaths++
+		}
+	}
+}
+
+// due reports whether a TurnStats event should be sent for turn.
+func (s *statsCounter) due(turn int) bool {
+	return s != nil && turn%s.interval == 0
+}
+
+// report returns the statistics of world and starts counting births and deaths afresh.
+func (s *statsCounter) report(turn int, world [][]byte) TurnStats {
+	stats := TurnStats{CompletedTurns: turn, Births: s.births, Deaths: s.deaths}
+	s.births, s.deaths = 0, 0
+
+	hash := fnv.New64a()
+	sumX, sumY := 0, 0
+	for y, row := range world {
+		hash.Write(row)
+		for x, cell := range row {
+			if cell == 0 {
+				continue
+			}
+			stats.Alive++
+			sumX += x
+			sumY += y
+			stats.Bounds = stats.Bounds.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	if stats.Alive > 0 {
+		stats.CentroidX = float64(sumX) / float64(stats.Alive)
+		stats.CentroidY = float64(sumY) / float64(stats.Alive)
+	}
+	stats.Hash = hash.Sum64()
+	return stats
+}
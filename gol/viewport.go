@@ -0,0 +1,127 @@
+package gol
+
+import (
+	"image"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Viewport is the rectangle of the world that a front-end is watching, in cells.
+// When a run is given one with WithViewport, only cells inside it generate CellFlipped and CellsFlipped events,
+// while AliveCellsCount and FinalTurnComplete still cover the whole world.
+// Watch can be called at any time, e.g. as the front-end pans or zooms; the cells that differ
+// from what the front-end was last sent are then flipped, even while the simulation is paused.
+type Viewport struct {
+	mu      sync.Mutex
+	rect    image.Rectangle
+	all     bool
+	changed chan struct{}
+}
+
+// NewViewport returns a viewport that watches the whole world.
+func NewViewport() *Viewport {
+	return &Viewport{all: true, changed: make(chan struct{}, 1)}
+}
+
+// Watch restricts flip events to the cells within rect. Parts of rect outside the world are ignored.
+func (v *Viewport) Watch(rect image.Rectangle) {
+	v.mu.Lock()
+	v.rect = rect.Canon()
+	v.all = false
+	v.mu.Unlock()
+	v.notify()
+}
+
+// WatchAll sends flip events for the whole world again.
+func (v *Viewport) WatchAll() {
+	v.mu.Lock()
+	v.all = true
+	v.mu.Unlock()
+	v.notify()
+}
+
+func (v *Viewport) notify() {
+	select {
+	case v.changed <- struct{}{}:
+	default:
+	}
+}
+
+// bounds returns the watched cells of a world the size given by p.
+func (v *Viewport) bounds(p Params) image.Rectangle {
+	world := image.Rect(0, 0, p.ImageWidth, p.ImageHeight)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.all {
+		return world
+	}
+	return v.rect.Intersect(world)
+}
+
+// viewTracker remembers what the front-end has been sent, so that only cells inside its
+// viewport are flipped and moving the viewport flips just the cells that are out of date.
+// A nil viewTracker, used without a viewport, passes every flip through.
+type viewTracker struct {
+	viewport *Viewport
+	rect     image.Rectangle
+	// shown is the world as the front-end last saw it. Inside rect it always matches the world.
+	shown [][]byte
+}
+
+func newViewTracker(p Params, viewport *Viewport) *viewTracker {
+	if viewport == nil {
+		return nil
+	}
+	shown := make([][]byte, p.ImageHeight)
+	for y := range shown {
+		shown[y] = make([]byte, p.ImageWidth)
+	}
+	return &viewTracker{viewport: viewport, rect: viewport.bounds(p), shown: shown}
+}
+
+// changes returns a channel that is ready when the viewport has moved, or nil without a viewport.
+func (t *viewTracker) changes() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.viewport.changed
+}
+
+// flips returns the cells to report after the world changed by flipped.
+func (t *viewTracker) flips(p Params, world [][]byte, flipped []util.Cell) []util.Cell {
+	if t == nil {
+		return flipped
+	}
+	if rect := t.viewport.bounds(p); rect != t.rect {
+		t.rect = rect
+		return t.resync(world)
+	}
+	var visible []util.Cell
+	for _, cell := range flipped {
+		if image.Pt(cell.X, cell.Y).In(t.rect) {
+			t.shown[cell.Y][cell.X] = world[cell.Y][cell.X]
+			visible = append(visible, cell)
+		}
+	}
+	return visible
+}
+
+// moved returns the cells to report after the viewport moved without the world changing.
+func (t *viewTracker) moved(p Params, world [][]byte) []util.Cell {
+	return t.flips(p, world, nil)
+}
+
+// resync flips every cell in the viewport that differs from what the front-end was last sent.
+func (t *viewTracker) resync(world [][]byte) []util.Cell {
+	var stale []util.Cell
+	for y := t.rect.Min.Y; y < t.rect.Max.Y; y++ {
+		for x := t.rect.Min.X; x < t.rect.Max.X; x++ {
+			if t.shown[y][x] != world[y][x] {
+				t.shown[y][x] = world[y][x]
+				stale = append(stale, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return stale
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/sdl"
+)
+
+// TestHttp tests that browsers are sent a snapshot of the world followed by flips, and can send key presses.
+func TestHttp(t *testing.T) {
+	keyPresses := make(chan rune, 10)
+	view := sdl.NewHttpView(16, 16, keyPresses)
+	server := httptest.NewServer(view)
+	defer server.Close()
+	defer view.Close()
+
+	view.FlipPixel(1, 2)
+	view.SetTurn(0)
+
+	viewers := make([]*bufio.Reader, 2)
+	for i := range viewers {
+		response, err := http.Get(server.URL + "/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		viewers[i] = bufio.NewReader(response.Body)
+	}
+
+	view.FlipPixel(3, 4)
+	view.SetTurn(1)
+	view.Flush()
+	view.SetState(gol.Paused)
+
+	for i, viewer := range viewers {
+		world := readHttpMessage(t, viewer)
+		assert(t, world["type"] == "world", "Viewer %v should be sent the world first, not %v\n", i, world["type"])
+		assert(t, len(world["cells"].([]interface{})) == 2, "Viewer %v should be sent 1 alive cell, not %v\n", i, world["cells"])
+
+		flip := readHttpMessage(t, viewer)
+		assert(t, flip["type"] == "flip", "Viewer %v should be sent flips after the world, not %v\n", i, flip["type"])
+		assert(t, flip["turn"] == 1.0, "Viewer %v should be sent turn 1, not %v\n", i, flip["turn"])
+		assert(t, flip["alive"] == 2.0, "Viewer %v should be sent 2 alive cells, not %v\n", i, flip["alive"])
+
+		state := readHttpMessage(t, viewer)
+		assert(t, state["state"] == "Paused", "Viewer %v should be sent the Paused state, not %v\n", i, state["state"])
+	}
+
+	response, err := http.Post(server.URL+"/key", "text/plain", strings.NewReader("p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert(t, response.StatusCode == http.StatusNoContent, "Posting p should succeed, not %v\n", response.Status)
+	assert(t, len(keyPresses) == 1 && <-keyPresses == 'p', "Posting p should send p to keyPresses\n")
+
+	response, err = http.Post(server.URL+"/key", "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert(t, response.StatusCode == http.StatusBadRequest, "Posting x should be rejected, not %v\n", response.Status)
+
+	// Other sites' pages, including those that rebind their name to this server, can't press keys.
+	for _, header := range []struct{ origin, host string }{
+		{"http://example.com", ""},
+		{"", "example.com"},
+		{"http://example.com", "example.com"},
+	} {
+		request, err := http.NewRequest(http.MethodPost, server.URL+"/key", strings.NewReader("q"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.origin != "" {
+			request.Header.Set("Origin", header.origin)
+		}
+		if header.host != "" {
+			request.Host = header.host
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		assert(t, response.StatusCode == http.StatusForbidden, "Posting with Origin %q and Host %q should be forbidden, not %v\n", header.origin, header.host, response.Status)
+	}
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/key", strings.NewReader("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Origin", server.URL)
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert(t, response.StatusCode == http.StatusNoContent, "Posting from the page's own origin should succeed, not %v\n", response.Status)
+	assert(t, len(keyPresses) == 1 && <-keyPresses == 's', "Only the key posted from the page should be sent to keyPresses\n")
+}
+
+func readHttpMessage(t *testing.T, viewer *bufio.Reader) map[string]interface{} {
+	for {
+		line, err := viewer.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data := strings.TrimPrefix(line, "data: "); data != line {
+			var message map[string]interface{}
+			if err := json.Unmarshal([]byte(data), &message); err != nil {
+				t.Fatal(err)
+			}
+			return message
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestJson tests that every event marshals to its tagged JSON form and decodes back to the same typed event.
+func TestJson(t *testing.T) {
+	events := []gol.Event{
+		gol.AliveCellsCount{CompletedTurns: 10, CellsCount: 5},
+		gol.ImageOutputComplete{CompletedTurns: 10, Filename: "16x16x10"},
+		gol.StateChange{CompletedTurns: 3, NewState: gol.Paused},
+		gol.CellFlipped{CompletedTurns: 1, Cell: util.Cell{X: 2, Y: 3}},
+		gol.CellsFlipped{CompletedTurns: 1, Cells: []util.Cell{{X: 0, Y: 1}, {X: 4, Y: 5}}},
+		gol.TurnComplete{CompletedTurns: 1},
+		gol.TurnStats{CompletedTurns: 1, Births: 2, Deaths: 1, Alive: 3, Bounds: image.Rect(1, 2, 4, 3), CentroidX: 2, CentroidY: 2, Hash: 0xcbf29ce484222325},
+		gol.RuleChange{CompletedTurns: 4, Rule: "B36/S23"},
+		gol.FinalTurnComplete{CompletedTurns: 100, Alive: []util.Cell{{X: 7, Y: 8}}},
+		gol.ErrorEvent{CompletedTurns: 0, Err: errors.New("images/16x16.pgm: not found")},
+	}
+
+	data, err := json.Marshal(gol.AliveCellsCount{CompletedTurns: 10, CellsCount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"AliveCellsCount","completed_turns":10,"payload":{"cells_count":5}}`
+	assert(t, string(data) == expected, "AliveCellsCount should marshal to %v, not %v\n", expected, string(data))
+
+	var stream bytes.Buffer
+	encoder := gol.NewEventEncoder(&stream)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert(t, strings.Count(stream.String(), "\n") == len(events), "Each event should be written on its own line\n")
+	for _, line := range strings.Split(strings.TrimSuffix(stream.String(), "\n"), "\n") {
+		assert(t, json.Valid([]byte(line)) && strings.Contains(line, `"elapsed_us":`), "Each line should be a timed JSON event, not %v\n", line)
+	}
+
+	decoder := gol.NewEventDecoder(&stream)
+	for _, event := range events {
+		decoded, err := decoder.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, ok := event.(gol.ErrorEvent); ok {
+			d, ok := decoded.(gol.ErrorEvent)
+			assert(t, ok && d.CompletedTurns == e.CompletedTurns && d.Err.Error() == e.Err.Error(), "Expected %#v, got %#v\n", event, decoded)
+			continue
+		}
+		assert(t, reflect.DeepEqual(decoded, event), "Expected %#v, got %#v\n", event, decoded)
+	}
+	_, err = decoder.Decode()
+	assert(t, err == io.EOF, "Decoding past the last event should return io.EOF, not %v\n", err)
+
+	_, err = gol.DecodeEvent([]byte(`{"type":"Teleported","completed_turns":1}`))
+	assert(t, err != nil, "Unknown event types should be rejected\n")
+}
+
+// TestJsonRecording tests that a recorded run decodes to the events that were forwarded.
+func TestJsonRecording(t *testing.T) {
+	params := gol.Params{Turns: 10, Threads: 4, ImageWidth: 16, ImageHeight: 16, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	forwarded := make(chan gol.Event, 1000)
+	go gol.Run(params, events, make(chan rune, 10))
+
+	var stream bytes.Buffer
+	recorded := make(chan error, 1)
+	go func() { recorded <- gol.TeeEvents(&stream, events, forwarded) }()
+
+	var received []gol.Event
+	for event := range forwarded {
+		received = append(received, event)
+	}
+	if err := <-recorded; err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := gol.NewEventDecoder(&stream)
+	for i, event := range received {
+		decoded, err := decoder.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, reflect.DeepEqual(decoded, event), "Event %v should be recorded as %#v, not %#v\n", i, event, decoded)
+	}
+	final := false
+	for _, event := range received {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e.CompletedTurns == 10
+		}
+	}
+	assert(t, final, "The recording should include the FinalTurnComplete event\n")
+}
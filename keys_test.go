@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/sdl"
+)
+
+// TestKeyBindings tests that key bindings are loaded from a file, then overridden by inline bindings.
+func TestKeyBindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	err := os.WriteFile(path, []byte("# AZERTY\na = quit\nq = none\n\nx=kill, space=pause\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bindings, err := sdl.LoadKeyBindings(gol.Params{KeyFile: path, KeyBindings: "X=snapshot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]sdl.Command{
+		"a":      sdl.CommandQuit,
+		"x":      sdl.CommandSnapshot,
+		"space":  sdl.CommandPause,
+		"p":      sdl.CommandPause,
+		"escape": sdl.CommandQuit,
+	}
+	for key, command := range expected {
+		assert(t, bindings[key] == command, "Key %q should be bound to %v, not %q\n", key, command, bindings[key])
+	}
+	_, bound := bindings["q"]
+	assert(t, !bound, "Key q should have been unbound\n")
+
+	for _, invalid := range []string{"p=fly", "p", "=pause", "spcae=pause", "period=zoomin"} {
+		_, err := sdl.LoadKeyBindings(gol.Params{KeyBindings: invalid})
+		assert(t, err != nil, "Bindings %q should be rejected\n", invalid)
+	}
+	_, err = sdl.LoadKeyBindings(gol.Params{KeyFile: filepath.Join(t.TempDir(), "missing")})
+	assert(t, err != nil, "A missing key file should be reported\n")
+
+	// The keys that separate bindings can be bound, literally or by name.
+	_, err = sdl.LoadKeyBindings(gol.Params{KeyBindings: "==zoomout,comma=step,-=equals"})
+	assert(t, err != nil, "Equals is a key, not a command\n")
+	bindings, err = sdl.LoadKeyBindings(gol.Params{KeyBindings: "==zoomout,comma=step,.=zoomin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, bindings["="] == sdl.CommandZoomOut, "Key = should be bound to zoomout, not %q\n", bindings["="])
+	assert(t, bindings[","] == sdl.CommandStep, "Key , should be bound to step, not %q\n", bindings[","])
+	assert(t, bindings["."] == sdl.CommandZoomIn, "Key . should be bound to zoomin, not %q\n", bindings["."])
+	assert(t, bindings["n"] == sdl.CommandStep, "Key n should be bound to step by default, not %q\n", bindings["n"])
+	reparsed := sdl.KeyBindings{}
+	if err := reparsed.Parse(strings.NewReader(bindings.String())); err != nil {
+		t.Fatal(err)
+	}
+	assert(t, reparsed.String() == bindings.String(), "Listed bindings should parse back to %v, not %v\n", bindings, reparsed)
+	command, ok := gol.KeyCommand('n')
+	assert(t, ok && command == gol.Step{Turns: 1}, "The n key should step a single turn, not %v\n", command)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestMacrocell tests 16x16, 64x64 and 512x512 Macrocell output files on 0, 1 and 100 turns.
+func TestMacrocell(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			p.Threads = 8
+			p.OutputFormat = gol.MacrocellFormat
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+
+			emptyOutFolder()
+
+			testName := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns)
+			t.Run(testName, func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for range events {
+				}
+				cellsFromImage := readMacrocellCells(
+					"out/"+fmt.Sprintf("%vx%vx%v.mc", p.ImageWidth, p.ImageHeight, turns),
+					p.ImageWidth,
+					p.ImageHeight,
+				)
+				assertEqualBoard(t, cellsFromImage, expectedAlive, p)
+			})
+		}
+	}
+}
+
+// readMacrocellCells expands a Macrocell file whose root has its top-left corner at (0, 0).
+func readMacrocellCells(path string, width, height int) []util.Cell {
+	data, ioError := os.ReadFile(path)
+	util.Check(ioError)
+
+	lines := strings.Split(string(data), "\n")
+	if !strings.HasPrefix(lines[0], "[M2]") {
+		panic("Not a macrocell file")
+	}
+
+	type node struct {
+		level    int
+		cells    []util.Cell
+		children [4]int
+	}
+	nodes := []node{{}}
+	for _, line := range lines[1:] {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '.' || line[0] == '*' || line[0] == '$' {
+			leaf := node{level: 3}
+			x, y := 0, 0
+			for _, c := range line {
+				switch c {
+				case '.':
+					x++
+				case '*':
+					leaf.cells = append(leaf.cells, util.Cell{X: x, Y: y})
+					x++
+				case '$':
+					x, y = 0, y+1
+				}
+			}
+			nodes = append(nodes, leaf)
+			continue
+		}
+		var n node
+		_, err := fmt.Sscan(line, &n.level, &n.children[0], &n.children[1], &n.children[2], &n.children[3])
+		util.Check(err)
+		nodes = append(nodes, n)
+	}
+
+	var cells []util.Cell
+	var expand func(index, x0, y0 int)
+	expand = func(index, x0, y0 int) {
+		if index == 0 {
+			return
+		}
+		n := nodes[index]
+		for _, cell := range n.cells {
+			cells = append(cells, util.Cell{X: x0 + cell.X, Y: y0 + cell.Y})
+		}
+		half := 1 << uint(n.level-1)
+		for i, child := range n.children {
+			expand(child, x0+(i%2)*half, y0+(i/2)*half)
+		}
+	}
+	expand(len(nodes)-1, 0, 0)
+
+	for _, cell := range cells {
+		if cell.X >= width || cell.Y >= height {
+			panic("Macrocell pattern does not fit the world")
+		}
+	}
+	return cells
+}
+
+// TestMacrocellRoundTrip tests that a Macrocell snapshot can be loaded back in as the initial world.
+func TestMacrocellRoundTrip(t *testing.T) {
+	p := gol.Params{
+		Turns:        100,
+		Threads:      8,
+		ImageWidth:   64,
+		ImageHeight:  64,
+		OutputFormat: gol.MacrocellFormat,
+		OutputDir:    t.TempDir(),
+	}
+	runFinalAlive(p)
+
+	p.Turns = 0
+	p.InputPath = filepath.Join(p.OutputDir, "64x64x100.mc")
+	assertEqualBoard(t, runFinalAlive(p), readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight), p)
+}
+
+// TestMacrocellRule tests that Macrocell images record the rule in use when they were written, which SetRule changes.
+func TestMacrocellRule(t *testing.T) {
+	p := gol.Params{
+		Turns:          10000000,
+		Threads:        4,
+		ImageWidth:     16,
+		ImageHeight:    16,
+		OutputFormat:   gol.MacrocellFormat,
+		OutputDir:      t.TempDir(),
+		OutputTemplate: "{turn}-{rule}",
+	}
+	events := make(chan gol.Event, 1000)
+	requests := make(chan gol.Request)
+	go func() {
+		for range events {
+		}
+	}()
+	golDone := make(chan bool)
+	go func() {
+		gol.RunCommands(context.Background(), p, events, requests)
+		golDone <- true
+	}()
+
+	send := func(command gol.Command) {
+		var err error
+		timeout(t, 5*time.Second, func() { err = <-gol.Send(requests, command) }, "The "+command.String()+" command should be acknowledged")
+		assert(t, err == nil, "%v should be acknowledged without an error, not %v\n", command, err)
+	}
+	send(gol.Pause{})
+	send(gol.Snapshot{})
+	send(gol.SetRule{Rule: "B36/S23"})
+	send(gol.Snapshot{})
+
+	for _, rule := range []string{"B3/S23", "B36/S23"} {
+		paths, _ := filepath.Glob(filepath.Join(p.OutputDir, "*-"+strings.ReplaceAll(rule, "/", "")+".mc"))
+		assert(t, len(paths) == 1, "There should be one snapshot taken under %v, not %v\n", rule, len(paths))
+		if len(paths) == 1 {
+			data, ioError := os.ReadFile(paths[0])
+			util.Check(ioError)
+			header := strings.SplitN(string(data), "\n", 3)
+			assert(t, len(header) == 3 && header[1] == "#R "+rule, "The snapshot taken under %v should record it in %q\n", rule, header)
+		}
+	}
+	send(gol.Quit{})
+	timeout(t, 5*time.Second, func() { <-golDone }, "The run should stop after Quit")
+}
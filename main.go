@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"net/http"
 	"runtime"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"uk.ac.bris.cs/gameoflife/gol"
@@ -41,13 +44,193 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.StringVar(
+		&params.InputFormat,
+		"informat",
+		"",
+		"Specify the format of the input image, pgm or mc. Defaults to the extension of -input, or pgm.")
+
+	flag.StringVar(
+		&params.InputPath,
+		"input",
+		"",
+		"Specify the image to load. Defaults to images/<w>x<h>.<informat>.")
+
+	flag.StringVar(
+		&params.OutputFormat,
+		"outformat",
+		gol.PgmFormat,
+		"Specify the format of output images, pgm, mc or png. Defaults to pgm.")
+
+	flag.StringVar(
+		&params.OutputDir,
+		"outdir",
+		"out",
+		"Specify the directory output images are written to. Defaults to out.")
+
+	flag.StringVar(
+		&params.OutputTemplate,
+		"outname",
+		gol.DefaultOutputTemplate,
+		"Specify the output filename template. {w}, {h}, {turn}, {threads}, {rule} and {pid} are replaced. Defaults to "+gol.DefaultOutputTemplate+".")
+
+	flag.IntVar(
+		&params.GifInterval,
+		"gif",
+		0,
+		"Record every Nth turn into an animated GIF in out/. Defaults to 0 (press r to record instead).")
+
+	flag.IntVar(
+		&params.GifScale,
+		"gifscale",
+		1,
+		"Specify the size of a cell in recorded GIFs, in pixels. Defaults to 1.")
+
+	flag.IntVar(
+		&params.GifDelay,
+		"gifdelay",
+		10,
+		"Specify the delay between recorded GIF frames, in 100ths of a second. Defaults to 10.")
+
+	flag.IntVar(
+		&params.FrameInterval,
+		"frames",
+		0,
+		"Write every Nth turn of the window to numbered PNG frames in <outdir>/frames, also when headless. Defaults to 0 (off).")
+
+	flag.IntVar(
+		&params.FrameScale,
+		"framescale",
+		1,
+		"Specify the size of a cell in recorded frames, in pixels. Defaults to 1.")
+
+	flag.StringVar(
+		&params.KeyFile,
+		"keys",
+		"",
+		"Load key bindings for the SDL window from a file of key=command lines.")
+
+	flag.StringVar(
+		&params.KeyBindings,
+		"bind",
+		"",
+		"Bind keys to commands, e.g. x=quit,space=pause,comma=step. Commands are pause, snapshot, quit, kill, record, step, colour, hud, graph, export, zoomin, zoomout, panleft, panright, panup, pandown and none. The = and , keys are called equals and comma.")
+
+	flag.IntVar(
+		&params.Zoom,
+		"zoom",
+		0,
+		"Specify the size of a cell in the SDL window, in pixels. Defaults to 0, which fits small boards to 512 pixels.")
+
+	flag.IntVar(
+		&params.GridZoom,
+		"grid",
+		0,
+		"Draw gridlines between cells when the zoom is at least this. Defaults to 0 (off).")
+
+	flag.StringVar(
+		&params.ThemeFile,
+		"theme",
+		"",
+		"Load the palette from a file of name=#rrggbb lines, naming background, alive, dying and grid.")
+
+	flag.StringVar(
+		&params.Palette,
+		"palette",
+		"",
+		"Set palette colours, e.g. alive=#ffd000,background=#102030. Applied after -theme.")
+
+	eventsOut := flag.String(
+		"events-out",
+		"",
+		"Record every event to this file as newline-delimited JSON.")
+
+	var replay gol.ReplayParams
+	flag.StringVar(
+		&replay.Path,
+		"replay",
+		"",
+		"Replay an event log recorded with -events-out instead of running the simulation. -w and -h must match the log.")
+
+	flag.Float64Var(
+		&replay.Speed,
+		"speed",
+		1,
+		"Specify the replay speed as a multiple of the original. Defaults to 1; 0 replays as fast as possible.")
+
+	flag.IntVar(
+		&replay.Seek,
+		"seek",
+		0,
+		"Skip to this turn of a replay, replaying earlier events without delay. Defaults to 0.")
+
+	compareInput := flag.String(
+		"compare-input",
+		"",
+		"Compare against a second run that loads this image instead.")
+
+	compareThreads := flag.Int(
+		"compare-threads",
+		0,
+		"Compare against a second run that uses this many worker threads instead.")
+
+	diff := flag.Bool(
+		"diff",
+		false,
+		"Show a comparison as one diff overlay instead of side by side.")
+
 	headless := flag.Bool(
 		"headless",
 		false,
 		"Disable the SDL window for running in a headless environment.")
 
+	tui := flag.Bool(
+		"tui",
+		false,
+		"Draw the world in the terminal instead of an SDL window, e.g. over SSH.")
+
+	httpAddr := flag.String(
+		"http",
+		"",
+		"Serve the world to browsers on this address, e.g. :8080, instead of opening an SDL window.")
+
+	flag.IntVar(
+		&params.StatsInterval,
+		"stats",
+		0,
+		"Send a TurnStats event with births, deaths, bounds, centroid and a world hash every Nth turn, e.g. for -events-out. Defaults to 0 (off).")
+
+	metricsAddr := flag.String(
+		"metrics",
+		"",
+		"Serve Prometheus metrics at /metrics on this address, e.g. :9090.")
+
+	backpressure := flag.String(
+		"backpressure",
+		"block",
+		"Specify what happens to events the front-end falls behind on: block, queue, coalesce, drop or resync. Defaults to block, which holds the simulation back once -buffer events are waiting.")
+
+	buffer := flag.Int(
+		"buffer",
+		1000,
+		"Specify how many events the front-end may fall behind by with -backpressure block, drop or resync. Defaults to 1000.")
+
 	flag.Parse()
 
+	policy, err := gol.ParseBufferPolicy(*backpressure)
+	if err != nil {
+		fmt.Printf("Backpressure: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := sdl.LoadKeyBindings(params); err != nil {
+		fmt.Printf("Key bindings: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := sdl.LoadPalette(params); err != nil {
+		fmt.Printf("Palette: %v\n", err)
+		os.Exit(2)
+	}
+
 	fmt.Printf("%-10v %v\n", "Threads", params.Threads)
 	fmt.Printf("%-10v %v\n", "Width", params.ImageWidth)
 	fmt.Printf("%-10v %v\n", "Height", params.ImageHeight)
@@ -56,19 +239,139 @@ func main() {
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
 
+	if *compareInput != "" || *compareThreads > 0 {
+		second := params
+		second.OutputDir = filepath.Join(params.OutputDir, "compare")
+		if *compareInput != "" {
+			second.InputPath = *compareInput
+		}
+		if *compareThreads > 0 {
+			second.Threads = *compareThreads
+		}
+		compare(params, second, *headless, *diff)
+		return
+	}
+
 	go sigterm(keyPresses)
 
-	go gol.Run(params, events, keyPresses)
-	if !(*headless) {
-		sdl.Run(params, events, keyPresses)
+	var options []gol.Option
+	var viewport *gol.Viewport
+	if *httpAddr == "" && !*tui && !*headless {
+		// The SDL window can zoom in on part of the board, and only needs flips for the cells it shows.
+		viewport = gol.NewViewport()
+		options = append(options, gol.WithViewport(viewport))
+	}
+	var metrics *gol.Metrics
+	if *metricsAddr != "" {
+		metrics = gol.NewMetrics()
+		options = append(options, gol.WithMetrics(metrics))
+		go serveMetrics(*metricsAddr, metrics)
+	}
+	if replay.Path != "" {
+		go gol.Replay(params, replay, events, keyPresses)
 	} else {
-		sdl.RunHeadless(events)
+		go gol.Run(params, events, keyPresses, options...)
+	}
+	// The front-end and the event recorder subscribe separately, so neither can hold up the other.
+	bus := gol.NewBus()
+	frontEnd := bus.Subscribe(gol.SubscribeOptions{Policy: policy, Buffer: *buffer})
+	if metrics != nil {
+		metrics.Watch(frontEnd)
+	}
+	received := frontEnd.Events
+	recorded := make(chan error, 1)
+	if *eventsOut != "" {
+		go recordEvents(*eventsOut, bus.Subscribe(gol.SubscribeOptions{}).Events, recorded)
+	} else {
+		recorded <- nil
+	}
+	go bus.Forward(events)
+
+	if *httpAddr != "" {
+		sdl.RunHttp(*httpAddr, params, received, keyPresses)
+	} else if *tui {
+		sdl.RunTui(params, received, keyPresses)
+	} else if !(*headless) {
+		sdl.Run(params, received, keyPresses, viewport)
+	} else {
+		sdl.RunHeadless(params, received)
+	}
+	if stats := frontEnd.Stats(); stats.Coalesced+stats.Dropped > 0 {
+		fmt.Printf("Events coalesced %v, dropped %v, resyncs %v\n", stats.Coalesced, stats.Dropped, stats.Resyncs)
+	}
+
+	if err := <-recorded; err != nil {
+		fmt.Printf("Recording events failed: %v\n", err)
 	}
 }
 
-func sigterm(keyPresses chan<- rune) {
+// serveMetrics serves metrics at /metrics on addr for as long as the program runs.
+func serveMetrics(addr string, metrics *gol.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(metrics))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Printf("Metrics: %v\n", err)
+	}
+}
+
+// metricsHandler serves metrics to a Prometheus scraper.
+func metricsHandler(metrics *gol.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.WriteTo(w)
+	})
+}
+
+// recordEvents writes every event from events to the file at path as newline-delimited JSON.
+// The result is sent on recorded once the events channel is closed.
+func recordEvents(path string, events <-chan gol.Event, recorded chan<- error) {
+	file, err := os.Create(path)
+	if err != nil {
+		for range events {
+		}
+		recorded <- err
+		return
+	}
+	w := bufio.NewWriter(file)
+	encoder := gol.NewEventEncoder(w)
+	for event := range events {
+		if err == nil {
+			err = encoder.Encode(event)
+		}
+	}
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	recorded <- err
+}
+
+// compare runs two simulations that differ only in second's parameters and reports where they diverge.
+func compare(first, second gol.Params, headless, diff bool) {
+	firstKeys := make(chan rune, 10)
+	secondKeys := make(chan rune, 10)
+	firstEvents := make(chan gol.Event, 1000)
+	secondEvents := make(chan gol.Event, 1000)
+
+	go sigterm(firstKeys, secondKeys)
+
+	go gol.Run(first, firstEvents, firstKeys)
+	go gol.Run(second, secondEvents, secondKeys)
+	events := [2]<-chan gol.Event{firstEvents, secondEvents}
+	if !headless {
+		sdl.RunCompare(first, events, [2]chan<- rune{firstKeys, secondKeys}, diff)
+	} else {
+		sdl.CompareHeadless(first, events)
+	}
+}
+
+func sigterm(keyPresses ...chan<- rune) {
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGTERM, syscall.SIGINT)
 	<-sigterm
-	keyPresses <- 'q'
+	for _, keys := range keyPresses {
+		keys <- 'q'
+	}
 }
@@ -11,72 +11,90 @@ import (
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
-var w *sdl.Window
-var flipCellChan chan util.Cell
-var refreshChan chan struct{}
-var clearPixelsChan chan struct{}
+// testDisplay is a Display whose cells can be checked against the expected images.
+type testDisplay interface {
+	sdl.Display
+	AliveCells() []util.Cell
+}
+
+var display testDisplay
+
+// displayCalls queues calls to the display. They are made in order on the main thread, as SDL
+// requires, so that checks always see every flip sent before them.
+var displayCalls chan func()
+var dirty bool
 
 func TestMain(m *testing.M) {
 	runtime.LockOSThread()
 	var sdlFlag = flag.Bool(
 		"sdl",
 		false,
-		"Enable the SDL window for testing.")
+		"Enable the SDL window for testing. Otherwise cells are drawn on an in-memory display.")
 
 	flag.Parse()
 	done := make(chan int, 1)
 	test := func() { done <- m.Run() }
-	if !(*sdlFlag) {
-		go test()
+
+	var window *sdl.Window
+	if *sdlFlag {
+		window = sdl.NewWindow(512, 512)
+		display = window
 	} else {
-		w = sdl.NewWindow(512, 512)
-		flipCellChan = make(chan util.Cell, 1000)
-		refreshChan = make(chan struct{}, 1)
-		clearPixelsChan = make(chan struct{}, 1)
-		fps := 60
-		ticker := time.NewTicker(time.Second / time.Duration(fps))
-		dirty := false
-		go test()
-	loop:
-		for {
-			select {
-			case code := <-done:
-				done <- code
-				w.Destroy()
-				break loop
-			case <-ticker.C:
-				w.PollEvent()
-				if dirty {
-					w.RenderFrame()
-					dirty = false
-				}
-			case cell := <-flipCellChan:
-				w.FlipPixel(cell.X, cell.Y)
-			case <-clearPixelsChan:
-				w.ClearPixels()
-				w.RenderFrame()
-			case <-refreshChan:
-				dirty = true
+		display = sdl.NewMemoryDisplay(512, 512)
+	}
+	displayCalls = make(chan func(), 1000)
+	fps := 60
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	go test()
+loop:
+	for {
+		select {
+		case code := <-done:
+			done <- code
+			if window != nil {
+				window.Destroy()
+			}
+			break loop
+		case <-ticker.C:
+			if window != nil {
+				window.PollEvent()
+			}
+			if dirty {
+				display.RenderFrame()
+				dirty = false
 			}
+		case call := <-displayCalls:
+			call()
 		}
 	}
 	os.Exit(<-done)
 }
 
 func flipCell(cell util.Cell) {
-	if flipCellChan != nil {
-		flipCellChan <- cell
-	}
+	displayCalls <- func() { display.FlipPixel(cell.X, cell.Y) }
 }
 
 func refresh() {
-	if refreshChan != nil {
-		refreshChan <- struct{}{}
-	}
+	displayCalls <- func() { dirty = true }
 }
 
 func clearPixels() {
-	if clearPixelsChan != nil {
-		clearPixelsChan <- struct{}{}
+	displayCalls <- func() {
+		display.ClearPixels()
+		display.RenderFrame()
 	}
 }
+
+// displayedCells returns the cells drawn as alive, once every earlier call has been made.
+func displayedCells() []util.Cell {
+	reply := make(chan []util.Cell)
+	displayCalls <- func() { reply <- display.AliveCells() }
+	return <-reply
+}
+
+// displayedCount returns the display's count of alive cells, once every earlier call has been made.
+func displayedCount() int {
+	reply := make(chan int)
+	displayCalls <- func() { reply <- display.CountPixels() }
+	return <-reply
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestMetrics tests that a run's progress can be scraped from /metrics in the Prometheus text format.
+func TestMetrics(t *testing.T) {
+	metrics := gol.NewMetrics()
+	params := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	golDone := make(chan bool, 1)
+	go func() {
+		gol.Run(params, events, make(chan rune, 10), gol.WithMetrics(metrics))
+		golDone <- true
+	}()
+	var alive int
+	timeout(t, 5*time.Second, func() {
+		for event := range events {
+			if e, ok := event.(gol.FinalTurnComplete); ok {
+				alive = len(e.Alive)
+			}
+		}
+		<-golDone
+	}, "The run should finish")
+
+	server := httptest.NewServer(metricsHandler(metrics))
+	defer server.Close()
+	response, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(body)
+
+	assert(t, strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain"), "Metrics should be served as text/plain\n")
+	expected := []string{
+		"# TYPE gol_turns_completed_total counter\n",
+		"\ngol_turns_completed_total 100\n",
+		"\ngol_alive_cells " + strconv.Itoa(alive) + "\n",
+		"\ngol_turns_per_second ",
+		"\ngol_worker_step_seconds_count{worker=\"3\"} 100\n",
+		"\ngol_event_channel_capacity 1000\n",
+		"\ngol_io_busy_seconds_total ",
+	}
+	for _, line := range expected {
+		assert(t, strings.Contains(text, line), "The metrics should include %q, got:\n%v", line, text)
+	}
+
+	// Scraping only reads the metrics, so once the run is over every scrape is the same.
+	var first, second strings.Builder
+	metrics.WriteTo(&first)
+	time.Sleep(10 * time.Millisecond)
+	metrics.WriteTo(&second)
+	assert(t, first.String() == second.String(), "Scrapes of a finished run should match, got:\n%v\nthen:\n%v", first.String(), second.String())
+}
+
+// TestMetricsBacklog tests that the event depth reports the events waiting in a watched subscription,
+// rather than the events channel that its bus empties.
+func TestMetricsBacklog(t *testing.T) {
+	metrics := gol.NewMetrics()
+	params := gol.Params{Turns: 100, Threads: 4, ImageWidth: 16, ImageHeight: 16, OutputDir: t.TempDir()}
+	events := make(chan gol.Event)
+	bus := gol.NewBus()
+	subscription := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Block, Buffer: 50})
+	metrics.Watch(subscription)
+	go bus.Forward(events)
+	go gol.Run(params, events, nil, gol.WithMetrics(metrics))
+
+	// Nothing is received, so the subscription fills up and holds the run back.
+	var text string
+	timeout(t, 5*time.Second, func() {
+		for !strings.Contains(text, "\ngol_event_channel_depth 50\n") {
+			time.Sleep(10 * time.Millisecond)
+			var body strings.Builder
+			metrics.WriteTo(&body)
+			text = body.String()
+		}
+	}, "The depth should reach the subscription's buffer of 50 events")
+	assert(t, strings.Contains(text, "\ngol_event_channel_capacity 50\n"), "The capacity should be the subscription's buffer, got:\n%v", text)
+
+	timeout(t, 5*time.Second, func() {
+		for range subscription.Events {
+		}
+	}, "The run should finish once events are received")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestOutputTemplate tests that images are written to OutputDir and named after OutputTemplate.
+func TestOutputTemplate(t *testing.T) {
+	p := gol.Params{
+		Turns:          1,
+		Threads:        8,
+		ImageWidth:     16,
+		ImageHeight:    16,
+		OutputDir:      t.TempDir(),
+		OutputTemplate: "{w}x{h}x{turn}-{rule}.pgm",
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var filename string
+	for event := range events {
+		if e, ok := event.(gol.ImageOutputComplete); ok {
+			filename = e.Filename
+		}
+	}
+	if filename != "16x16x1-B3S23.pgm" {
+		t.Fatalf("ERROR: Expected output image 16x16x1-B3S23.pgm, got %q instead", filename)
+	}
+	assertEqualBoard(t,
+		readAliveCells(filepath.Join(p.OutputDir, filename), p.ImageWidth, p.ImageHeight),
+		readAliveCells("check/images/16x16x1.pgm", p.ImageWidth, p.ImageHeight),
+		p)
+}
+
+// TestInputPath tests that InputPath replaces the default image in images/.
+func TestInputPath(t *testing.T) {
+	p := gol.Params{
+		Turns:       0,
+		Threads:     8,
+		ImageWidth:  16,
+		ImageHeight: 16,
+		InputPath:   "check/images/16x16x100.pgm",
+		OutputDir:   t.TempDir(),
+	}
+	assertEqualBoard(t, runFinalAlive(p), readAliveCells(p.InputPath, p.ImageWidth, p.ImageHeight), p)
+}
+
+// runFinalAlive runs gol.Run to completion and returns the alive cells of its FinalTurnComplete event.
+func runFinalAlive(p gol.Params) []util.Cell {
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	for event := range events {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			cells = e.Alive
+		}
+	}
+	return cells
+}
+
+// TestOutputTemplateExtension tests that an image extension in OutputTemplate is replaced by that of the
+// file written, rather than having another extension appended to it.
+func TestOutputTemplateExtension(t *testing.T) {
+	p := gol.Params{
+		Turns:          1,
+		Threads:        8,
+		ImageWidth:     16,
+		ImageHeight:    16,
+		OutputDir:      t.TempDir(),
+		OutputTemplate: "{w}x{h}x{turn}.pgm",
+		OutputFormat:   gol.PngFormat,
+		GifInterval:    1,
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var filenames []string
+	for event := range events {
+		if e, ok := event.(gol.ImageOutputComplete); ok {
+			filenames = append(filenames, e.Filename)
+		}
+	}
+	expected := []string{"16x16x0-1.gif", "16x16x1.png"}
+	if strings.Join(filenames, " ") != strings.Join(expected, " ") {
+		t.Fatalf("ERROR: Expected outputs %v, got %v instead", expected, filenames)
+	}
+	for _, filename := range expected {
+		if _, err := os.Stat(filepath.Join(p.OutputDir, filename)); err != nil {
+			t.Errorf("ERROR: %v should have been written: %v", filename, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/sdl"
+)
+
+// TestPalette tests that palettes are loaded from a theme file and flags, and used to draw cells.
+func TestPalette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "theme")
+	err := os.WriteFile(path, []byte("# Solarized\nbackground = #002b36\nalive = #fdf6e3\ngrid=#073642\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	palette, err := sdl.LoadPalette(gol.Params{ThemeFile: path, Palette: "alive=#fd0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sdl.Palette{
+		Background: sdl.Colour{R: 0x00, G: 0x2b, B: 0x36},
+		Alive:      sdl.Colour{R: 0xff, G: 0xdd, B: 0x00},
+		Dying:      sdl.DefaultPalette().Dying,
+		Grid:       sdl.Colour{R: 0x07, G: 0x36, B: 0x42},
+	}
+	assert(t, palette == expected, "Palette should be %+v, not %+v\n", expected, palette)
+
+	for _, invalid := range []string{"alive=white", "alive=#12345", "border=#fff"} {
+		_, err := sdl.LoadPalette(gol.Params{Palette: invalid})
+		assert(t, err != nil, "Palette %q should be rejected\n", invalid)
+	}
+
+	display := sdl.NewMemoryDisplay(4, 4)
+	display.SetPalette(palette)
+	display.FlipPixel(1, 2)
+	display.RenderFrame()
+	frame := display.Frame()
+	for i := 0; i < 16; i++ {
+		colour := palette.Background
+		if i == 2*4+1 {
+			colour = palette.Alive
+		}
+		got := sdl.Colour{R: frame[4*i+2], G: frame[4*i+1], B: frame[4*i]}
+		assert(t, got == colour, "Pixel %v should be drawn in %v, not %v\n", i, colour, got)
+	}
+
+	display.ClearPixels()
+	frame = display.Frame()
+	got := sdl.Colour{R: frame[4*9+2], G: frame[4*9+1], B: frame[4*9]}
+	assert(t, got == palette.Background, "Cleared pixels should be drawn in %v, not %v\n", palette.Background, got)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestPng tests 16x16, 64x64 and 512x512 PNG output files on 0, 1 and 100 turns.
+func TestPng(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			p.Threads = 8
+			p.OutputFormat = gol.PngFormat
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+
+			emptyOutFolder()
+
+			testName := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns)
+			t.Run(testName, func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				for range events {
+				}
+				f, err := os.Open("out/" + fmt.Sprintf("%vx%vx%v.png", p.ImageWidth, p.ImageHeight, turns))
+				util.Check(err)
+				defer f.Close()
+				img, err := png.Decode(f)
+				util.Check(err)
+				assertEqualBoard(t, imageAliveCells(img, 1), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestGif tests that a 64x64 GIF recording every 50 turns contains the expected frames.
+func TestGif(t *testing.T) {
+	p := gol.Params{
+		Turns:       100,
+		Threads:     8,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		GifInterval: 50,
+		GifScale:    3,
+		GifDelay:    10,
+	}
+
+	emptyOutFolder()
+
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+
+	f, err := os.Open("out/64x64x0-100.gif")
+	util.Check(err)
+	defer f.Close()
+	animation, err := gif.DecodeAll(f)
+	util.Check(err)
+
+	if len(animation.Image) != 3 {
+		t.Fatalf("ERROR: Expected 3 GIF frames, got %v instead", len(animation.Image))
+	}
+	// Only turns 0 and 100 have reference images; they are the first and last frames.
+	for i, turns := range map[int]int{0: 0, 2: 100} {
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		assertEqualBoard(t, imageAliveCells(animation.Image[i], p.GifScale), expectedAlive, p)
+	}
+}
+
+// imageAliveCells returns the cells whose top-left pixel is not black in an image drawn at the given scale.
+func imageAliveCells(img image.Image, scale int) []util.Cell {
+	var cells []util.Cell
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += scale {
+		for x := bounds.Min.X; x < bounds.Max.X; x += scale {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if r != 0 || g != 0 || b != 0 {
+				cells = append(cells, util.Cell{X: x / scale, Y: y / scale})
+			}
+		}
+	}
+	return cells
+}
+
+// TestGifSplit tests that a recording too large to keep in memory is written as several GIFs.
+func TestGifSplit(t *testing.T) {
+	p := gol.Params{
+		Turns:       10,
+		Threads:     8,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		GifInterval: 1,
+		GifScale:    4,
+	}
+	// Each frame is 2048x2048 pixels, so 8 fit in MaxGifBytes.
+	limit := gol.MaxGifBytes / (2048 * 2048)
+
+	emptyOutFolder()
+
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var outputs []string
+	for event := range events {
+		if e, ok := event.(gol.ImageOutputComplete); ok {
+			outputs = append(outputs, e.Filename)
+		}
+	}
+
+	expected := []string{fmt.Sprintf("512x512x0-%v", limit-1), fmt.Sprintf("512x512x%v-10", limit-1)}
+	assert(t, fmt.Sprint(outputs[:2]) == fmt.Sprint(expected), "The recording should be written as %v, not %v\n", expected, outputs)
+	frames := 0
+	for _, filename := range expected {
+		f, err := os.Open("out/" + filename + ".gif")
+		util.Check(err)
+		animation, err := gif.DecodeAll(f)
+		f.Close()
+		util.Check(err)
+		frames += len(animation.Image)
+	}
+	assert(t, frames == p.Turns+1, "The GIFs should hold %v frames between them, not %v\n", p.Turns+1, frames)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestPopulation tests that the per-turn counts a canvas derives from CellsFlipped export in the same
+// format as check/alive, and are plotted in its panel.
+func TestPopulation(t *testing.T) {
+	p := gol.Params{
+		Turns:       100,
+		Threads:     8,
+		ImageWidth:  64,
+		ImageHeight: 64,
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+
+	display := sdl.NewMemoryDisplay(p.ImageWidth, p.ImageHeight)
+	for event := range events {
+		display.Apply(event)
+	}
+
+	var csv bytes.Buffer
+	util.Check(display.Population().WriteCSV(&csv))
+
+	data, err := os.ReadFile("check/alive/64x64.csv")
+	util.Check(err)
+	expected := strings.Join(strings.Split(strings.ReplaceAll(string(data), "\r", ""), "\n")[:p.Turns+1], "\n") + "\n"
+	if csv.String() != expected {
+		t.Errorf("ERROR: Exported population does not match check/alive/64x64.csv for the first %v turns", p.Turns)
+	}
+
+	// Each of the 100 samples is plotted in its own column of the panel, and nothing after them.
+	display.SetPanel(true)
+	display.RenderFrame()
+	panel := display.Panel(sdl.PanelWidth, p.ImageHeight)
+	plotted := func(x int) bool {
+		for y := 0; y < p.ImageHeight; y++ {
+			i := 4 * (y*sdl.PanelWidth + x)
+			if panel[i] == 0x40 && panel[i+1] == 0xFF && panel[i+2] == 0x40 {
+				return true
+			}
+		}
+		return false
+	}
+	assert(t, plotted(0) && plotted(p.Turns-1), "The first and last samples should be plotted in the panel\n")
+	assert(t, !plotted(p.Turns), "Nothing should be plotted after the last sample\n")
+}
+
+// TestPopulationLimit tests that a population only keeps its most recent samples.
+func TestPopulationLimit(t *testing.T) {
+	population := sdl.NewPopulation(3)
+	for turn := 0; turn < 5; turn++ {
+		population.Record(turn, turn*10)
+	}
+	population.Record(4, 45)
+
+	var csv bytes.Buffer
+	util.Check(population.WriteCSV(&csv))
+	expected := "completed_turns,alive_cells\n2,20\n3,30\n4,45\n"
+	assert(t, csv.String() == expected, "The population should keep the last 3 samples, got:\n%v", csv.String())
+	recent := population.Recent(2)
+	assert(t, len(recent) == 2 && recent[0].CompletedTurns == 3 && recent[1].AliveCells == 45, "The last 2 samples should be turns 3 and 4, got %v\n", recent)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestReplay tests that a recorded run replayed into the Tester shows the same images as the original run.
+func TestReplay(t *testing.T) {
+	params := gol.Params{
+		Turns:       100,
+		Threads:     8,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		OutputDir:   t.TempDir(),
+	}
+	path := filepath.Join(t.TempDir(), "run.ndjson")
+	recordRun(t, params, path)
+
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	golDone := make(chan bool, 1)
+	go func() {
+		gol.Replay(params, gol.ReplayParams{Path: path}, events, keyPresses)
+		golDone <- true
+	}()
+
+	tester := MakeTester(t, params, keyPresses, events, golDone)
+	tester.SetTestSdl()
+
+	go func() {
+		tester.TestStartsExecuting()
+		turn, success := tester.AwaitSync()
+		if !success {
+			tester.Stop(false)
+			return
+		}
+		tester.TestImage()
+		tester.Continue()
+
+		for turn < 100 {
+			turn, success = tester.AwaitSync()
+			if !success {
+				tester.Stop(false)
+				return
+			}
+			tester.TestAlive()
+			if turn == 1 || turn == 100 {
+				tester.TestImage()
+			}
+			tester.Continue()
+		}
+		tester.Stop(false)
+	}()
+
+	tester.Loop()
+}
+
+// TestReplaySpeed tests that replays keep the recorded timing, scaled by speed, after the seek turn.
+func TestReplaySpeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timed.ndjson")
+	log := []string{
+		`{"type":"StateChange","completed_turns":0,"payload":{"new_state":"Executing"},"elapsed_us":0}`,
+		`{"type":"TurnComplete","completed_turns":1,"elapsed_us":1000000}`,
+		`{"type":"TurnComplete","completed_turns":2,"elapsed_us":1200000}`,
+		`{"type":"TurnComplete","completed_turns":3,"elapsed_us":1400000}`,
+		`{"type":"StateChange","completed_turns":3,"payload":{"new_state":"Quitting"},"elapsed_us":1400000}`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(log, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	params := gol.Params{ImageWidth: 16, ImageHeight: 16}
+
+	// Seeking to turn 1 skips the first second, leaving 0.4s at double speed.
+	events := make(chan gol.Event, 10)
+	start := time.Now()
+	go gol.Replay(params, gol.ReplayParams{Path: path, Speed: 2, Seek: 1}, events, make(chan rune))
+	var received []gol.Event
+	for event := range events {
+		received = append(received, event)
+	}
+	took := time.Since(start)
+	assert(t, took > 150*time.Millisecond && took < 600*time.Millisecond, "Replay should take about 200ms, not %v\n", took)
+	assert(t, len(received) == 5, "Replay should send 4 events and Quitting, not %v events\n", len(received))
+
+	// Quitting stops the replay before the next event is due.
+	events = make(chan gol.Event, 10)
+	keyPresses := make(chan rune, 1)
+	keyPresses <- 'q'
+	go gol.Replay(params, gol.ReplayParams{Path: path, Speed: 1}, events, keyPresses)
+	timeout(t, 500*time.Millisecond, func() {
+		for range events {
+		}
+	}, "Replay should stop when q is pressed")
+}
+
+// recordRun runs the simulation and records its events to path.
+func recordRun(t *testing.T, params gol.Params, path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+
+	events := make(chan gol.Event, 1000)
+	forwarded := make(chan gol.Event, 1000)
+	go gol.Run(params, events, make(chan rune, 10))
+	recorded := make(chan error, 1)
+	go func() { recorded <- gol.TeeEvents(w, events, forwarded) }()
+	for range forwarded {
+	}
+	if err := <-recorded; err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,171 @@
+package sdl
+
+import (
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Canvas is the pixel buffer behind a Window: cell colours plus the overlay, and the population panel.
+// It needs no display, so headless runs can render the same frames as the window.
+type Canvas struct {
+	width, height int
+	pixels        []byte
+	history       cellHistory
+	mode          ColourMode
+	palette       Palette
+	hud           []string
+	hudVisible    bool
+	frame         []byte
+	population    Population
+	panel         []byte
+	panelVisible  bool
+	// partial is set while only some cells are kept up to date, so they can't be counted.
+	partial bool
+}
+
+func NewCanvas(width, height int) *Canvas {
+	c := &Canvas{
+		width,
+		height,
+		make([]byte, width*height*4),
+		newCellHistory(width * height),
+		ColourPlain,
+		DefaultPalette(),
+		nil,
+		false,
+		make([]byte, width*height*4),
+		Population{},
+		nil,
+		false,
+		false,
+	}
+	c.paintAll()
+	return c
+}
+
+// Frame returns the ARGB8888 pixels to display, with the overlay drawn on top if it is visible.
+// The slice is reused, so it is only valid until the canvas next changes.
+func (c *Canvas) Frame() []byte {
+	// Apart from ColourPlain, colours depend on the turn, so every cell is repainted.
+	if c.mode != ColourPlain {
+		c.paintAll()
+	}
+	// The overlay is drawn on a copy, so that it never overwrites cell colours.
+	if c.hudVisible && len(c.hud) > 0 {
+		copy(c.frame, c.pixels)
+		c.drawHud(c.frame)
+		return c.frame
+	}
+	return c.pixels
+}
+
+// paint sets the pixel of cell i to the colour of the current mode.
+// Pixels are ARGB8888, stored as B, G, R, A bytes.
+func (c *Canvas) paint(i int) {
+	r, g, b := c.history.colour(i, c.mode, &c.palette)
+	c.pixels[4*i+0] = b
+	c.pixels[4*i+1] = g
+	c.pixels[4*i+2] = r
+	c.pixels[4*i+3] = 0xFF
+}
+
+func (c *Canvas) paintAll() {
+	for i := range c.history.alive {
+		c.paint(i)
+	}
+}
+
+func (c *Canvas) SetPixel(x, y int) {
+	i := y*c.width + x
+	if !c.history.alive[i] {
+		c.history.flip(i)
+	}
+	c.paint(i)
+}
+
+func (c *Canvas) FlipPixel(x, y int) {
+	if x < 0 || y < 0 || x >= c.width || y >= c.height {
+		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+
+	i := y*c.width + x
+	c.history.flip(i)
+	c.paint(i)
+}
+
+// Apply draws the flips of a run's events, and records its population from the cells drawn at every
+// TurnComplete, corrected by AliveCellsCount. Other events are ignored.
+func (c *Canvas) Apply(event gol.Event) {
+	switch e := event.(type) {
+	case gol.CellFlipped:
+		c.SetTurn(e.CompletedTurns)
+		c.FlipPixel(e.Cell.X, e.Cell.Y)
+	case gol.CellsFlipped:
+		c.SetTurn(e.CompletedTurns)
+		for _, cell := range e.Cells {
+			c.FlipPixel(cell.X, cell.Y)
+		}
+	case gol.TurnComplete:
+		c.SetTurn(e.CompletedTurns)
+		if !c.partial {
+			c.population.Record(e.CompletedTurns, c.CountPixels())
+		}
+	case gol.AliveCellsCount:
+		c.population.Record(e.CompletedTurns, e.CellsCount)
+	}
+}
+
+// SetPartial tells the canvas whether only some of its cells are kept up to date, e.g. while a window
+// only watches the part of the board it shows. Its cells aren't counted at TurnComplete meanwhile.
+func (c *Canvas) SetPartial(partial bool) {
+	c.partial = partial
+}
+
+// SetTurn tells the canvas which turn the following flips belong to, so that it can track cell ages.
+func (c *Canvas) SetTurn(turn int) {
+	c.history.turn = turn
+}
+
+// ColourMode returns how the canvas currently colours cells.
+func (c *Canvas) ColourMode() ColourMode {
+	return c.mode
+}
+
+// SetColourMode changes how cells are coloured and repaints the whole canvas.
+func (c *Canvas) SetColourMode(mode ColourMode) {
+	c.mode = mode
+	c.paintAll()
+}
+
+// Palette returns the colours the canvas draws with.
+func (c *Canvas) Palette() Palette {
+	return c.palette
+}
+
+// SetPalette changes the colours cells are drawn with and repaints the whole canvas.
+func (c *Canvas) SetPalette(palette Palette) {
+	c.palette = palette
+	c.paintAll()
+}
+
+func (c *Canvas) CountPixels() int {
+	return c.history.count
+}
+
+// AliveCells returns every cell that is currently drawn as alive.
+func (c *Canvas) AliveCells() []util.Cell {
+	var cells []util.Cell
+	for i, alive := range c.history.alive {
+		if alive {
+			cells = append(cells, util.Cell{X: i % c.width, Y: i / c.width})
+		}
+	}
+	return cells
+}
+
+func (c *Canvas) ClearPixels() {
+	c.history = newCellHistory(c.width * c.height)
+	c.paintAll()
+}
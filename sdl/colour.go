@@ -0,0 +1,130 @@
+package sdl
+
+// ColourMode selects how Window colours cells.
+type ColourMode int
+
+const (
+	// ColourPlain draws alive cells white and dead cells black.
+	ColourPlain ColourMode = iota
+	// ColourAge fades alive cells from white to blue as they survive more turns.
+	ColourAge
+	// ColourChanges draws cells born this turn in green and cells that died recently in fading red.
+	ColourChanges
+	// ColourHeatmap colours every cell by how many times it has flipped since the window was cleared.
+	ColourHeatmap
+)
+
+const (
+	// maxAge is the age in turns at which ColourAge stops fading a cell.
+	maxAge = 100
+	// deathFade is the number of turns a dead cell stays red in ColourChanges.
+	deathFade = 4
+)
+
+func (mode ColourMode) String() string {
+	switch mode {
+	case ColourPlain:
+		return "Plain"
+	case ColourAge:
+		return "Age"
+	case ColourChanges:
+		return "Changes"
+	case ColourHeatmap:
+		return "Heatmap"
+	default:
+		return "Incorrect ColourMode"
+	}
+}
+
+// Next returns the mode after this one, wrapping around to ColourPlain.
+func (mode ColourMode) Next() ColourMode {
+	return (mode + 1) % (ColourHeatmap + 1)
+}
+
+// cellHistory is the per-cell state a Window keeps so that it can colour cells by their history.
+type cellHistory struct {
+	turn     int
+	count    int
+	alive    []bool
+	changed  []int
+	flips    []uint32
+	maxFlips uint32
+}
+
+func newCellHistory(size int) cellHistory {
+	return cellHistory{
+		alive:   make([]bool, size),
+		changed: make([]int, size),
+		flips:   make([]uint32, size),
+	}
+}
+
+// flip records that cell i changed state during the current turn.
+func (h *cellHistory) flip(i int) {
+	h.alive[i] = !h.alive[i]
+	if h.alive[i] {
+		h.count++
+	} else {
+		h.count--
+	}
+	h.changed[i] = h.turn
+	h.flips[i]++
+	if h.flips[i] > h.maxFlips {
+		h.maxFlips = h.flips[i]
+	}
+}
+
+// colour returns the red, green and blue components of cell i in the given mode and palette.
+func (h *cellHistory) colour(i int, mode ColourMode, palette *Palette) (r, g, b byte) {
+	background := palette.Background
+	switch mode {
+	case ColourAge:
+		if !h.alive[i] {
+			return background.R, background.G, background.B
+		}
+		age := h.turn - h.changed[i]
+		if age > maxAge {
+			age = maxAge
+		}
+		return lerpColour(palette.Alive, Colour{0x1E, 0x3C, 0xFF}, age, maxAge)
+	case ColourChanges:
+		since := h.turn - h.changed[i]
+		if h.alive[i] {
+			if since == 0 {
+				return 0, 0xFF, 0
+			}
+			return palette.Alive.R, palette.Alive.G, palette.Alive.B
+		}
+		if h.flips[i] > 0 && since < deathFade {
+			return lerpColour(palette.Dying, background, since, deathFade)
+		}
+		return background.R, background.G, background.B
+	case ColourHeatmap:
+		if h.flips[i] == 0 {
+			return background.R, background.G, background.B
+		}
+		// Black, through red and yellow, to white for the most active cells.
+		heat := int(h.flips[i]) * 3 * 0xFF / int(h.maxFlips)
+		return clampByte(heat), clampByte(heat - 0xFF), clampByte(heat - 2*0xFF)
+	default:
+		if h.alive[i] {
+			return palette.Alive.R, palette.Alive.G, palette.Alive.B
+		}
+		return background.R, background.G, background.B
+	}
+}
+
+// lerp interpolates from a to b as step goes from 0 to steps.
+func lerp(a, b byte, step, steps int) byte {
+	return byte(int(a) + (int(b)-int(a))*step/steps)
+}
+
+func clampByte(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 0xFF {
+		return 0xFF
+	}
+	return byte(v)
+}
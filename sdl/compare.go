@@ -0,0 +1,281 @@
+package sdl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Colours of cells that are only alive in the first or only in the second run of a comparison.
+var (
+	onlyFirstColour  = Colour{0xFF, 0x40, 0x40}
+	onlySecondColour = Colour{0x40, 0xA0, 0xFF}
+)
+
+// compareGap is the width in cells of the gap between the two runs when shown side by side.
+const compareGap = 2
+
+// Comparison follows two runs of the same size turn by turn and finds where they first diverge.
+// The runs are compared whenever both have completed the same turn, so a run that gets ahead
+// must not be handled any more events until the other catches up, as reported by Blocked.
+type Comparison struct {
+	Width, Height int
+	// Turn is the last turn both runs completed, and Differences the number of cells that differed then.
+	Turn        int
+	Differences int
+	// Diverged is the first turn at which the runs differed, or -1 while they are identical.
+	Diverged int
+
+	alive       [2][]bool
+	differences int
+	synced      [2]int
+	started     [2]bool
+	finished    [2]bool
+}
+
+func NewComparison(width, height int) *Comparison {
+	return &Comparison{
+		Width:    width,
+		Height:   height,
+		Diverged: -1,
+		alive:    [2][]bool{make([]bool, width*height), make([]bool, width*height)},
+	}
+}
+
+// Handle applies an event from the given run, 0 or 1, and reports whether the runs were compared.
+func (c *Comparison) Handle(run int, event gol.Event) bool {
+	switch e := event.(type) {
+	case gol.CellFlipped:
+		c.flip(run, e.Cell)
+	case gol.CellsFlipped:
+		for _, cell := range e.Cells {
+			c.flip(run, cell)
+		}
+	case gol.TurnComplete:
+		c.synced[run] = e.CompletedTurns + 1
+		return c.compare()
+	case gol.StateChange:
+		switch {
+		case e.NewState == gol.Executing && !c.started[run]:
+			// The initial world is complete once the run starts executing.
+			c.started[run] = true
+			c.synced[run] = 1
+			return c.compare()
+		case e.NewState == gol.Quitting:
+			c.Finish(run)
+		}
+	}
+	return false
+}
+
+// Finish records that a run has ended, so that the other is no longer held back for it.
+func (c *Comparison) Finish(run int) {
+	c.finished[run] = true
+}
+
+// Finished reports whether a run has ended.
+func (c *Comparison) Finished(run int) bool {
+	return c.finished[run]
+}
+
+// Blocked reports whether a run is ahead of the other and must wait before more of its events are handled.
+func (c *Comparison) Blocked(run int) bool {
+	return c.synced[run] > c.synced[1-run] && !c.finished[1-run]
+}
+
+func (c *Comparison) flip(run int, cell util.Cell) {
+	i := cell.Y*c.Width + cell.X
+	c.alive[run][i] = !c.alive[run][i]
+	if c.alive[run][i] != c.alive[1-run][i] {
+		c.differences++
+	} else {
+		c.differences--
+	}
+}
+
+func (c *Comparison) compare() bool {
+	if c.synced[0] != c.synced[1] {
+		return false
+	}
+	c.Turn = c.synced[0] - 1
+	c.Differences = c.differences
+	if c.Differences > 0 && c.Diverged < 0 {
+		c.Diverged = c.Turn
+	}
+	return true
+}
+
+// String summarises the comparison so far.
+func (c *Comparison) String() string {
+	if c.Diverged < 0 {
+		return fmt.Sprintf("Identical for %v turns", c.Turn)
+	}
+	return fmt.Sprintf("Diverged at turn %v, %v cells differ at turn %v", c.Diverged, c.Differences, c.Turn)
+}
+
+// render draws the runs side by side, or overlaid with diff, into ARGB8888 pixels.
+// Cells alive in only one run are drawn in that run's colour.
+func (c *Comparison) render(pixels []byte, width int, diff bool, palette Palette) {
+	set := func(x, y int, colour Colour) {
+		i := 4 * (y*width + x)
+		pixels[i+0] = colour.B
+		pixels[i+1] = colour.G
+		pixels[i+2] = colour.R
+		pixels[i+3] = 0xFF
+	}
+	cellColour := func(alive, other bool, only Colour) Colour {
+		switch {
+		case alive && other:
+			return palette.Alive
+		case alive:
+			return only
+		default:
+			return palette.Background
+		}
+	}
+
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			i := y*c.Width + x
+			first, second := c.alive[0][i], c.alive[1][i]
+			if diff {
+				colour := cellColour(first, second, onlyFirstColour)
+				if second && !first {
+					colour = onlySecondColour
+				}
+				set(x, y, colour)
+				continue
+			}
+			set(x, y, cellColour(first, second, onlyFirstColour))
+			set(x+c.Width+compareGap, y, cellColour(second, first, onlySecondColour))
+		}
+		if !diff {
+			for x := c.Width; x < c.Width+compareGap; x++ {
+				set(x, y, palette.Grid)
+			}
+		}
+	}
+
+	status := fmt.Sprintf("Same to turn %v", c.Turn)
+	if c.Diverged >= 0 {
+		status = fmt.Sprintf("Diverged at turn %v", c.Diverged)
+	}
+	drawText(pixels, width, c.Height, width/256+1, 1, 1, status, 0xFF, 0xD0, 0x00)
+}
+
+// inputs returns the channels to receive from next. A run that is ahead is held back by leaving
+// its channel nil, which the engine notices as back pressure once its event buffer is full.
+func (c *Comparison) inputs(events [2]<-chan gol.Event) [2]<-chan gol.Event {
+	var in [2]<-chan gol.Event
+	for run := range in {
+		if !c.Finished(run) && !c.Blocked(run) {
+			in[run] = events[run]
+		}
+	}
+	return in
+}
+
+// receive handles an event received from a run, where !ok means that its channel was closed.
+// It reports whether the runs were compared, printing the turn at which they first diverge.
+func (c *Comparison) receive(run int, event gol.Event, ok bool) bool {
+	if !ok {
+		c.Finish(run)
+		return false
+	}
+	diverged := c.Diverged
+	compared := c.Handle(run, event)
+	if diverged < 0 && c.Diverged >= 0 {
+		fmt.Println(c)
+	}
+	return compared
+}
+
+// compareKey forwards a key press to both runs, skipping any that can't take more key presses.
+func compareKey(keyPresses [2]chan<- rune, key rune) {
+	for _, keys := range keyPresses {
+		select {
+		case keys <- key:
+		default:
+		}
+	}
+}
+
+// RunCompare shows two runs of the same size in one window, side by side or as a diff overlay,
+// and reports the first turn at which they diverge. Key presses are sent to both runs.
+func RunCompare(p gol.Params, events [2]<-chan gol.Event, keyPresses [2]chan<- rune, diff bool) {
+	c := NewComparison(p.ImageWidth, p.ImageHeight)
+	width := p.ImageWidth
+	if !diff {
+		width = 2*p.ImageWidth + compareGap
+	}
+	w := NewWindow(int32(width), int32(p.ImageHeight))
+	defer w.Destroy()
+	w.SetZoom(zoom(p), 0)
+	palette := loadPalette(p)
+	pixels := make([]byte, width*p.ImageHeight*4)
+	bindings, err := LoadKeyBindings(p)
+	if err != nil {
+		fmt.Printf("Key bindings: %v, using the defaults\n", err)
+		bindings = DefaultKeyBindings()
+	}
+
+	dirty := true
+	refreshTicker := time.NewTicker(time.Second / time.Duration(FPS))
+	defer refreshTicker.Stop()
+
+	for !c.Finished(0) || !c.Finished(1) {
+		in := c.inputs(events)
+		run := 0
+		var event gol.Event
+		var ok bool
+		select {
+		case <-refreshTicker.C:
+			switch e := w.PollEvent().(type) {
+			case *sdl.QuitEvent:
+				compareKey(keyPresses, 'q')
+			case *sdl.KeyboardEvent:
+				if command, bound := bindings.lookup(e.Keysym.Sym); bound {
+					if key, forwarded := commandKeys[command]; forwarded {
+						compareKey(keyPresses, key)
+					}
+				}
+			}
+			if dirty {
+				c.render(pixels, width, diff, palette)
+				w.render(pixels)
+				dirty = false
+			}
+			continue
+		case event, ok = <-in[0]:
+		case event, ok = <-in[1]:
+			run = 1
+		}
+
+		if c.receive(run, event, ok) {
+			dirty = true
+		}
+	}
+	fmt.Println(c)
+}
+
+// CompareHeadless follows two runs without a window and returns how they compared once both have ended.
+func CompareHeadless(p gol.Params, events [2]<-chan gol.Event) *Comparison {
+	c := NewComparison(p.ImageWidth, p.ImageHeight)
+	for !c.Finished(0) || !c.Finished(1) {
+		in := c.inputs(events)
+		run := 0
+		var event gol.Event
+		var ok bool
+		select {
+		case event, ok = <-in[0]:
+		case event, ok = <-in[1]:
+			run = 1
+		}
+		c.receive(run, event, ok)
+	}
+	fmt.Println(c)
+	return c
+}
@@ -0,0 +1,31 @@
+package sdl
+
+// Display is a surface that cells are drawn on. Window shows it in an SDL window, while
+// MemoryDisplay only keeps the pixels, so that the same checks can run without a display.
+type Display interface {
+	FlipPixel(x, y int)
+	RenderFrame()
+	CountPixels() int
+	ClearPixels()
+}
+
+// MemoryDisplay is a Display that renders frames into memory instead of onto the screen.
+type MemoryDisplay struct {
+	*Canvas
+	// Frames counts the frames rendered so far.
+	Frames int
+}
+
+func NewMemoryDisplay(width, height int) *MemoryDisplay {
+	return &MemoryDisplay{NewCanvas(width, height), 0}
+}
+
+// RenderFrame composes the frame a window would show, so that it can be inspected with Frame,
+// and plots the population panel, as tall as the board, if it is shown.
+func (m *MemoryDisplay) RenderFrame() {
+	m.Frame()
+	if m.PanelVisible() {
+		m.Panel(PanelWidth, m.height)
+	}
+	m.Frames++
+}
@@ -0,0 +1,71 @@
+package sdl
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// FrameRecorder writes canvas frames to numbered PNG images, frame-000000.png onwards,
+// e.g. for ffmpeg -i frame-%06d.png. Frames are numbered consecutively whatever the interval.
+type FrameRecorder struct {
+	dir      string
+	interval int
+	scale    int
+	frames   int
+	last     int
+}
+
+// NewFrameRecorder records every interval-th turn into dir, drawing each cell as scale x scale pixels.
+func NewFrameRecorder(dir string, interval, scale int) *FrameRecorder {
+	if interval < 1 {
+		interval = 1
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	return &FrameRecorder{dir, interval, scale, 0, -1}
+}
+
+// Capture writes the canvas as the next frame if the turn is due and hasn't been written already.
+func (r *FrameRecorder) Capture(c *Canvas, turn int) error {
+	if turn%r.interval != 0 || turn == r.last {
+		return nil
+	}
+	r.last = turn
+
+	if err := os.MkdirAll(r.dir, os.ModePerm); err != nil {
+		return err
+	}
+	filename := filepath.Join(r.dir, fmt.Sprintf("frame-%06d.png", r.frames))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, r.render(c)); err != nil {
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	r.frames++
+	return file.Close()
+}
+
+// render converts the canvas' ARGB8888 frame into an image, scaled by nearest neighbour.
+func (r *FrameRecorder) render(c *Canvas) *image.RGBA {
+	frame := c.Frame()
+	pixels := image.NewRGBA(image.Rect(0, 0, c.width*r.scale, c.height*r.scale))
+	for y := 0; y < c.height*r.scale; y++ {
+		for x := 0; x < c.width*r.scale; x++ {
+			from := 4 * ((y/r.scale)*c.width + x/r.scale)
+			to := pixels.PixOffset(x, y)
+			pixels.Pix[to+0] = frame[from+2]
+			pixels.Pix[to+1] = frame[from+1]
+			pixels.Pix[to+2] = frame[from+0]
+			pixels.Pix[to+3] = 0xFF
+		}
+	}
+	return pixels
+}
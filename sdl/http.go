@@ -0,0 +1,350 @@
+package sdl
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//go:embed http.html
+var httpPage []byte
+
+// HttpFPS is how often flips are sent to browsers. Flips within a frame are coalesced.
+const HttpFPS = 30
+
+// httpViewerBuffer is how many messages a browser may fall behind by before it is disconnected.
+// EventSource reconnects automatically and resynchronises from a fresh snapshot.
+const httpViewerBuffer = 64
+
+// httpMessage is a single Server-Sent Event. Cells are flattened to x0, y0, x1, y1, ...
+type httpMessage struct {
+	Type        string `json:"type"`
+	Turn        int    `json:"turn"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Cells       []int  `json:"cells,omitempty"`
+	Alive       int    `json:"alive"`
+	TurnsPerSec int    `json:"turnsPerSec"`
+	State       string `json:"state,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+// HttpView keeps a copy of the world and streams it to any number of browsers.
+// Late viewers are sent a snapshot of the whole world, followed by the flips since.
+type HttpView struct {
+	Width, Height int
+	keyPresses    chan<- rune
+
+	mu      sync.Mutex
+	world   [][]bool
+	pending map[util.Cell]bool
+	overlay hud
+	sent    int
+	viewers map[chan []byte]bool
+}
+
+func NewHttpView(width, height int, keyPresses chan<- rune) *HttpView {
+	world := make([][]bool, height)
+	for i := range world {
+		world[i] = make([]bool, width)
+	}
+	return &HttpView{
+		Width:      width,
+		Height:     height,
+		keyPresses: keyPresses,
+		world:      world,
+		pending:    make(map[util.Cell]bool),
+		overlay:    hud{state: gol.Executing},
+		viewers:    make(map[chan []byte]bool),
+	}
+}
+
+// ServeHTTP serves the page on /, the event stream on /events and accepts key presses on /key.
+func (v *HttpView) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(httpPage)
+	case "/events":
+		v.serveEvents(w, r)
+	case "/key":
+		v.serveKey(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (v *HttpView) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	messages := v.subscribe()
+	defer v.unsubscribe(messages)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", message)
+			flusher.Flush()
+		}
+	}
+}
+
+// serveKey forwards a key posted by a browser, e.g. curl -d p localhost:8080/key
+func (v *HttpView) serveKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "key presses must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+	if !trustedKeyRequest(r) {
+		http.Error(w, "key presses must come from this page", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16))
+	if err != nil || len(body) != 1 {
+		http.Error(w, "expected a single key", http.StatusBadRequest)
+		return
+	}
+	switch key := rune(body[0]); key {
+	case 'p', 's', 'q', 'k', 'r', 'n':
+		select {
+		case v.keyPresses <- key:
+			w.WriteHeader(http.StatusNoContent)
+		case <-r.Context().Done():
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown key %q", key), http.StatusBadRequest)
+	}
+}
+
+// trustedKeyRequest reports whether a key press comes from the page served on /, or from a tool such as curl,
+// which sends no Origin. Other sites' pages can post without a preflight, so their Origin is rejected.
+// The server must also be named by localhost or an IP address, as a site that rebinds its own name
+// to this server would otherwise pass as the same origin.
+func trustedKeyRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if host != "localhost" && net.ParseIP(strings.Trim(host, "[]")) == nil {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// subscribe registers a new viewer and queues a snapshot of the world as its first message.
+func (v *HttpView) subscribe() chan []byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Existing viewers are sent the pending flips first, so that the snapshot is up to date.
+	v.flush()
+	var cells []int
+	for y, row := range v.world {
+		for x, alive := range row {
+			if alive {
+				cells = append(cells, x, y)
+			}
+		}
+	}
+
+	messages := make(chan []byte, httpViewerBuffer)
+	messages <- v.encode(httpMessage{Type: "world", Width: v.Width, Height: v.Height, Cells: cells, State: v.overlay.state.String()})
+	v.viewers[messages] = true
+	return messages
+}
+
+func (v *HttpView) unsubscribe(messages chan []byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.viewers[messages] {
+		delete(v.viewers, messages)
+		close(messages)
+	}
+}
+
+// encode fills in the fields every message carries. It must be called with v.mu held.
+func (v *HttpView) encode(message httpMessage) []byte {
+	message.Turn = v.overlay.turns
+	message.Alive = v.overlay.alive
+	message.TurnsPerSec = v.overlay.turnsPerSec
+	data, err := json.Marshal(message)
+	util.Check(err)
+	return data
+}
+
+// broadcast sends a message to every viewer, disconnecting those that have fallen too far behind.
+// It must be called with v.mu held.
+func (v *HttpView) broadcast(message httpMessage) {
+	data := v.encode(message)
+	for messages := range v.viewers {
+		select {
+		case messages <- data:
+		default:
+			delete(v.viewers, messages)
+			close(messages)
+		}
+	}
+}
+
+// FlipPixel records a flipped cell, to be sent with the next Flush.
+func (v *HttpView) FlipPixel(x, y int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	cell := util.Cell{X: x, Y: y}
+	v.pending[cell] = !v.pending[cell]
+	if v.world[y][x] == v.pending[cell] {
+		v.overlay.alive--
+	} else {
+		v.overlay.alive++
+	}
+}
+
+// Flush applies the pending flips to the world and sends them to every viewer.
+func (v *HttpView) Flush() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.flush()
+}
+
+// flush is Flush for callers that already hold v.mu.
+func (v *HttpView) flush() {
+	var cells []int
+	for cell, flipped := range v.pending {
+		if flipped {
+			v.world[cell.Y][cell.X] = !v.world[cell.Y][cell.X]
+			cells = append(cells, cell.X, cell.Y)
+		}
+	}
+	v.pending = make(map[util.Cell]bool)
+	// Nothing is sent while paused, or when the turn hasn't changed.
+	if len(cells) == 0 && v.sent == v.overlay.turns {
+		return
+	}
+	v.sent = v.overlay.turns
+	v.broadcast(httpMessage{Type: "flip", Cells: cells})
+}
+
+// SetTurn records the number of completed turns.
+func (v *HttpView) SetTurn(turn int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.overlay.turns = turn
+}
+
+// SetTurnsPerSec records the rate reported with each AliveCellsCount.
+func (v *HttpView) SetTurnsPerSec(turnsPerSec int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.overlay.turnsPerSec = turnsPerSec
+}
+
+// SetState tells every viewer that the state of the simulation has changed.
+func (v *HttpView) SetState(state gol.State) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.overlay.state = state
+	v.broadcast(httpMessage{Type: "state", State: state.String()})
+}
+
+// Message shows a line of text, such as a completed snapshot, to every viewer.
+func (v *HttpView) Message(text string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.broadcast(httpMessage{Type: "text", Text: text})
+}
+
+// Close disconnects every viewer.
+func (v *HttpView) Close() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for messages := range v.viewers {
+		delete(v.viewers, messages)
+		close(messages)
+	}
+}
+
+// RunHttp serves the world to browsers on addr instead of opening an SDL window.
+// Key presses posted by viewers of localhost or an IP address are forwarded to the simulation.
+func RunHttp(addr string, p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+	v := NewHttpView(p.ImageWidth, p.ImageHeight, keyPresses)
+	server := &http.Server{Addr: addr, Handler: v}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("HTTP server failed: %v\n", err)
+			keyPresses <- 'q'
+		}
+	}()
+	fmt.Printf("Serving on http://%v\n", addr)
+
+	refreshTicker := time.NewTicker(time.Second / time.Duration(HttpFPS))
+	defer refreshTicker.Stop()
+	avgTurns := util.NewAvgTurns()
+
+http:
+	for {
+		select {
+		case <-refreshTicker.C:
+			v.Flush()
+
+		case event, ok := <-events:
+			if !ok {
+				break http
+			}
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				v.FlipPixel(e.Cell.X, e.Cell.Y)
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					v.FlipPixel(cell.X, cell.Y)
+				}
+			case gol.TurnComplete:
+				v.SetTurn(e.CompletedTurns)
+			case gol.AliveCellsCount:
+				v.SetTurnsPerSec(avgTurns.Get(event.GetCompletedTurns()))
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.FinalTurnComplete, gol.ImageOutputComplete, gol.ErrorEvent:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+				v.Message(event.String())
+			case gol.StateChange:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+				v.Flush()
+				v.SetState(e.NewState)
+				if e.NewState == gol.Quitting {
+					break http
+				}
+			}
+		}
+	}
+
+	v.Flush()
+	v.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = server.Shutdown(ctx)
+}
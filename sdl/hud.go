@@ -0,0 +1,65 @@
+package sdl
+
+import (
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// hud is the state shown in the window's on-screen overlay.
+type hud struct {
+	turns       int
+	alive       int
+	turnsPerSec int
+	state       gol.State
+	// rule is the rule from the last RuleChange, which is gol.Rule until one is received.
+	rule string
+}
+
+func (h hud) lines() []string {
+	rule := h.rule
+	if rule == "" {
+		rule = gol.Rule
+	}
+	return []string{
+		fmt.Sprintf("Turn %v", h.turns),
+		fmt.Sprintf("Alive %v", h.alive),
+		fmt.Sprintf("Turns/s %v", h.turnsPerSec),
+		h.state.String(),
+		"Rule " + rule,
+	}
+}
+
+// SetHud sets the lines of text shown in the overlay, and whether the overlay is visible at all.
+func (c *Canvas) SetHud(lines []string, visible bool) {
+	c.hud = lines
+	c.hudVisible = visible
+}
+
+// drawHud draws the overlay text over a darkened box in the top-left corner of the frame.
+// The font is scaled up by one pixel for every 256 pixels of canvas width.
+func (c *Canvas) drawHud(frame []byte) {
+	width, height := c.width, c.height
+	scale := width/256 + 1
+	columns := 0
+	for _, line := range c.hud {
+		if len(line) > columns {
+			columns = len(line)
+		}
+	}
+	boxWidth := (columns*(glyphWidth+1) + 1) * scale
+	boxHeight := (len(c.hud)*(glyphHeight+2) + 1) * scale
+
+	for y := 0; y < boxHeight && y < height; y++ {
+		for x := 0; x < boxWidth && x < width; x++ {
+			i := 4 * (y*width + x)
+			frame[i+0] /= 4
+			frame[i+1] /= 4
+			frame[i+2] /= 4
+		}
+	}
+
+	for row, line := range c.hud {
+		drawText(frame, width, height, scale, scale, scale*(1+row*(glyphHeight+2)), line, 0xFF, 0xD0, 0x00)
+	}
+}
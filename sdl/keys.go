@@ -0,0 +1,192 @@
+package sdl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Command is a named action that a key can be bound to.
+type Command string
+
+// Commands forwarded to the distributor as key presses.
+const (
+	CommandPause    Command = "pause"
+	CommandSnapshot Command = "snapshot"
+	CommandQuit     Command = "quit"
+	CommandKill     Command = "kill"
+	CommandRecord   Command = "record"
+	CommandStep     Command = "step"
+)
+
+// Commands handled by the window itself.
+const (
+	CommandColour Command = "colour"
+	CommandHud    Command = "hud"
+	CommandGraph  Command = "graph"
+	CommandExport Command = "export"
+
+	CommandZoomIn   Command = "zoomin"
+	CommandZoomOut  Command = "zoomout"
+	CommandPanLeft  Command = "panleft"
+	CommandPanRight Command = "panright"
+	CommandPanUp    Command = "panup"
+	CommandPanDown  Command = "pandown"
+)
+
+// commandKeys are the key presses sent to the distributor for each forwarded command.
+var commandKeys = map[Command]rune{
+	CommandPause:    'p',
+	CommandSnapshot: 's',
+	CommandQuit:     'q',
+	CommandKill:     'k',
+	CommandRecord:   'r',
+	CommandStep:     'n',
+}
+
+var localCommands = map[Command]bool{
+	CommandColour: true,
+	CommandHud:    true,
+	CommandGraph:  true,
+	CommandExport: true,
+
+	CommandZoomIn:   true,
+	CommandZoomOut:  true,
+	CommandPanLeft:  true,
+	CommandPanRight: true,
+	CommandPanUp:    true,
+	CommandPanDown:  true,
+}
+
+// KeyBindings maps keys to commands. Keys are SDL key names in lower case, such as "p",
+// "escape" or "space", so bindings follow the keyboard layout rather than physical positions.
+type KeyBindings map[string]Command
+
+// keyNames name the keys that separate bindings and their parts, so that they can be bound.
+var keyNames = map[string]string{
+	"equals": "=",
+	"comma":  ",",
+}
+
+// DefaultKeyBindings returns the bindings used unless they are overridden.
+func DefaultKeyBindings() KeyBindings {
+	return KeyBindings{
+		"escape": CommandQuit,
+		"p":      CommandPause,
+		"s":      CommandSnapshot,
+		"q":      CommandQuit,
+		"k":      CommandKill,
+		"r":      CommandRecord,
+		"n":      CommandStep,
+		"c":      CommandColour,
+		"h":      CommandHud,
+		"g":      CommandGraph,
+		"e":      CommandExport,
+		"=":      CommandZoomIn,
+		"-":      CommandZoomOut,
+		"left":   CommandPanLeft,
+		"right":  CommandPanRight,
+		"up":     CommandPanUp,
+		"down":   CommandPanDown,
+	}
+}
+
+// Bind binds key to the named command, replacing any previous binding of that key.
+// Keys must have an SDL key name, but the = and , keys can also be called equals and comma.
+// The command "none" removes the binding.
+func (b KeyBindings) Bind(key, command string) error {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if name, ok := keyNames[key]; ok {
+		key = name
+	}
+	c := Command(strings.ToLower(strings.TrimSpace(command)))
+	if key == "" {
+		return fmt.Errorf("missing key for command %q", command)
+	}
+	if sdl.GetKeyFromName(key) == sdl.K_UNKNOWN {
+		return fmt.Errorf("unknown key %q for command %q", key, command)
+	}
+	if c == "none" {
+		delete(b, key)
+		return nil
+	}
+	if _, ok := commandKeys[c]; !ok && !localCommands[c] {
+		return fmt.Errorf("unknown command %q for key %q", command, key)
+	}
+	b[key] = c
+	return nil
+}
+
+// Parse reads bindings of the form key=command, separated by commas or newlines, such as "==zoomin"
+// or "comma=step". Blank lines and lines starting with # are ignored.
+func (b KeyBindings) Parse(r io.Reader) error {
+	return parseAssignments(r, "command", b.Bind)
+}
+
+// parseAssignments reads name=value pairs, separated by commas or newlines, and passes each to set.
+// Values never contain =, so the name is everything before the last one. Blank lines and lines starting with # are ignored.
+func parseAssignments(r io.Reader, valueName string, set func(name, value string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, assignment := range strings.Split(line, ",") {
+			i := strings.LastIndex(assignment, "=")
+			if i < 0 {
+				return fmt.Errorf("expected key=%v, not %q", valueName, strings.TrimSpace(assignment))
+			}
+			if err := set(assignment[:i], assignment[i+1:]); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// String lists the bindings in the same form that Parse reads.
+func (b KeyBindings) String() string {
+	var bindings []string
+	for key, command := range b {
+		for name, k := range keyNames {
+			if key == k {
+				key = name
+			}
+		}
+		bindings = append(bindings, fmt.Sprintf("%v=%v", key, command))
+	}
+	sort.Strings(bindings)
+	return strings.Join(bindings, ",")
+}
+
+// LoadKeyBindings starts from the defaults, then applies p.KeyFile and finally p.KeyBindings.
+func LoadKeyBindings(p gol.Params) (KeyBindings, error) {
+	bindings := DefaultKeyBindings()
+	if p.KeyFile != "" {
+		file, err := os.Open(p.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if err := bindings.Parse(file); err != nil {
+			return nil, fmt.Errorf("%v: %w", p.KeyFile, err)
+		}
+	}
+	if err := bindings.Parse(strings.NewReader(p.KeyBindings)); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// lookup returns the command bound to an SDL key.
+func (b KeyBindings) lookup(key sdl.Keycode) (Command, bool) {
+	command, ok := b[strings.ToLower(sdl.GetKeyName(key))]
+	return command, ok
+}
@@ -2,6 +2,8 @@ package sdl
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
@@ -10,12 +12,25 @@ import (
 
 const FPS = 60
 
-func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+// Run shows the events in an SDL window and sends key presses on. If viewport is not nil,
+// it is told which cells the window shows, so the run given the same viewport only flips those.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, viewport *gol.Viewport) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
 	defer w.Destroy()
+	w.SetZoom(zoom(p), p.GridZoom)
+	w.SetPalette(loadPalette(p))
 	dirty := false
 	refreshTicker := time.NewTicker(time.Second / time.Duration(FPS))
 	avgTurns := util.NewAvgTurns()
+	overlay := hud{state: gol.Executing}
+	showHud := true
+	frames := newFrames(p)
+	watch(viewport, w, frames)
+	bindings, err := LoadKeyBindings(p)
+	if err != nil {
+		fmt.Printf("Key bindings: %v, using the defaults\n", err)
+		bindings = DefaultKeyBindings()
+	}
 
 sdl:
 	for {
@@ -27,21 +42,40 @@ sdl:
 				case *sdl.QuitEvent:
 					keyPresses <- 'q'
 				case *sdl.KeyboardEvent:
-					switch e.Keysym.Sym {
-					case sdl.K_ESCAPE:
-						keyPresses <- 'q'
-					case sdl.K_p:
-						keyPresses <- 'p'
-					case sdl.K_s:
-						keyPresses <- 's'
-					case sdl.K_q:
-						keyPresses <- 'q'
-					case sdl.K_k:
-						keyPresses <- 'k'
+					command, ok := bindings.lookup(e.Keysym.Sym)
+					if !ok {
+						break
+					}
+					if key, ok := commandKeys[command]; ok {
+						keyPresses <- key
+						break
+					}
+					switch command {
+					case CommandColour:
+						w.SetColourMode(w.ColourMode().Next())
+						fmt.Printf("Colour mode %v\n", w.ColourMode())
+						dirty = true
+					case CommandHud:
+						showHud = !showHud
+						dirty = true
+					case CommandGraph:
+						w.SetPanel(!w.PanelVisible())
+						dirty = true
+					case CommandExport:
+						exportPopulation(p, w.Population())
+					case CommandZoomIn, CommandZoomOut:
+						w.Magnify(command == CommandZoomIn)
+						watch(viewport, w, frames)
+						dirty = true
+					case CommandPanLeft, CommandPanRight, CommandPanUp, CommandPanDown:
+						w.Pan(panDirection(command))
+						watch(viewport, w, frames)
+						dirty = true
 					}
 				}
 			}
 			if dirty {
+				w.SetHud(overlay.lines(), showHud)
 				w.RenderFrame()
 				dirty = false
 			}
@@ -50,46 +84,190 @@ sdl:
 			if !ok {
 				break sdl
 			}
+			w.Apply(event)
 			switch e := event.(type) {
-			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
 			case gol.CellsFlipped:
-				for _, cell := range e.Cells {
-					w.FlipPixel(cell.X, cell.Y) 
-				}
+				// Moving the viewport while paused flips cells without a TurnComplete.
+				dirty = true
 			case gol.TurnComplete:
+				overlay.turns = e.CompletedTurns
 				dirty = true
+				if frames != nil {
+					w.SetHud(overlay.lines(), showHud)
+					frames = captureFrame(frames, w.Canvas, e.CompletedTurns)
+				}
 			case gol.AliveCellsCount:
-				fmt.Printf("Completed Turns %-8v %-20v Avg%+5v turns/sec\n", event.GetCompletedTurns(), event, avgTurns.Get(event.GetCompletedTurns()))
+				overlay.alive = e.CellsCount
+				overlay.turnsPerSec = avgTurns.Get(event.GetCompletedTurns())
+				fmt.Printf("Completed Turns %-8v %-20v Avg%+5v turns/sec\n", event.GetCompletedTurns(), event, overlay.turnsPerSec)
+				dirty = true
 			case gol.FinalTurnComplete:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.ImageOutputComplete:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.ErrorEvent:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.StateChange:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+				overlay.state = e.NewState
+				dirty = true
+				if frames != nil && e.NewState == gol.Executing {
+					w.SetHud(overlay.lines(), showHud)
+					frames = captureFrame(frames, w.Canvas, e.CompletedTurns)
+				}
 				if e.NewState == gol.Quitting {
 					break sdl
 				}
+			case gol.RuleChange:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+				overlay.rule = e.Rule
+				dirty = true
 			}
 		}
 	}
 }
 
-func RunHeadless(events <-chan gol.Event) {
+// watch tells the distributor which cells the window shows, so that it only sends flips inside them.
+// Recorded frames show the whole board, so the whole board is watched while recording.
+// Cells outside the viewport aren't kept up to date, so the window can't count them meanwhile.
+func watch(viewport *gol.Viewport, w *Window, frames *FrameRecorder) {
+	if viewport == nil {
+		return
+	}
+	if frames != nil || !w.Zoomed() {
+		viewport.WatchAll()
+		w.SetPartial(false)
+		return
+	}
+	viewport.Watch(w.View())
+	w.SetPartial(true)
+}
+
+// panDirection returns the direction to pan the view in for a pan command.
+func panDirection(command Command) (int, int) {
+	switch command {
+	case CommandPanLeft:
+		return -1, 0
+	case CommandPanRight:
+		return 1, 0
+	case CommandPanUp:
+		return 0, -1
+	default:
+		return 0, 1
+	}
+}
+
+// loadPalette returns the palette asked for by p, falling back to the default if it can't be loaded.
+func loadPalette(p gol.Params) Palette {
+	palette, err := LoadPalette(p)
+	if err != nil {
+		fmt.Printf("Palette: %v, using the defaults\n", err)
+		return DefaultPalette()
+	}
+	return palette
+}
+
+// zoom returns the size of a cell in the window, making boards smaller than 512 cells fill 512 pixels by default.
+func zoom(p gol.Params) int {
+	if p.Zoom > 0 {
+		return p.Zoom
+	}
+	size := p.ImageWidth
+	if p.ImageHeight > size {
+		size = p.ImageHeight
+	}
+	if size <= 0 || size >= 512 {
+		return 1
+	}
+	return 512 / size
+}
+
+// outputDir is the directory the front-end writes files to, out by default.
+func outputDir(p gol.Params) string {
+	if p.OutputDir == "" {
+		return "out"
+	}
+	return p.OutputDir
+}
+
+// newFrames returns the frame recorder asked for by p, or nil if frames aren't being recorded.
+func newFrames(p gol.Params) *FrameRecorder {
+	if p.FrameInterval <= 0 {
+		return nil
+	}
+	return NewFrameRecorder(filepath.Join(outputDir(p), "frames"), p.FrameInterval, p.FrameScale)
+}
+
+// captureFrame records the canvas, and returns nil to stop recording if a frame could not be written.
+func captureFrame(frames *FrameRecorder, c *Canvas, turn int) *FrameRecorder {
+	if err := frames.Capture(c, turn); err != nil {
+		fmt.Printf("Frame recording failed: %v\n", err)
+		return nil
+	}
+	return frames
+}
+
+// exportPopulation writes the population of the last PopulationHistory turns to <w>x<h>-population.csv in the output directory.
+func exportPopulation(p gol.Params, population *Population) {
+	dir := outputDir(p)
+	_ = os.MkdirAll(dir, os.ModePerm)
+	filename := filepath.Join(dir, fmt.Sprintf("%vx%v-population.csv", p.ImageWidth, p.ImageHeight))
+	file, err := os.Create(filename)
+	if err == nil {
+		err = population.WriteCSV(file)
+		file.Close()
+	}
+	if err != nil {
+		fmt.Printf("Population export failed: %v\n", err)
+		return
+	}
+	fmt.Printf("Population exported to %v\n", filename)
+}
+
+// RunHeadless prints events to the terminal. If p.FrameInterval is set, it also maintains the
+// canvas a window would show, so that frames can be recorded without a display.
+func RunHeadless(p gol.Params, events <-chan gol.Event) {
 	avgTurns := util.NewAvgTurns()
+	frames := newFrames(p)
+	var canvas *Canvas
+	overlay := hud{state: gol.Executing}
+	if frames != nil {
+		canvas = NewCanvas(p.ImageWidth, p.ImageHeight)
+		canvas.SetPalette(loadPalette(p))
+	}
 	for event := range events {
+		if canvas != nil {
+			canvas.Apply(event)
+		}
 		switch e := event.(type) {
+		case gol.TurnComplete:
+			if frames != nil {
+				overlay.turns = e.CompletedTurns
+				canvas.SetHud(overlay.lines(), true)
+				frames = captureFrame(frames, canvas, e.CompletedTurns)
+			}
 		case gol.AliveCellsCount:
-			fmt.Printf("Completed Turns %-8v %-20v Avg%+5v turns/sec\n", event.GetCompletedTurns(), event, avgTurns.Get(event.GetCompletedTurns()))
+			overlay.alive = e.CellsCount
+			overlay.turnsPerSec = avgTurns.Get(event.GetCompletedTurns())
+			fmt.Printf("Completed Turns %-8v %-20v Avg%+5v turns/sec\n", event.GetCompletedTurns(), event, overlay.turnsPerSec)
 		case gol.FinalTurnComplete:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), "Final Turn Complete")
 		case gol.ImageOutputComplete:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.ErrorEvent:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 		case gol.StateChange:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			overlay.state = e.NewState
+			if frames != nil && e.NewState == gol.Executing {
+				canvas.SetHud(overlay.lines(), true)
+				frames = captureFrame(frames, canvas, e.CompletedTurns)
+			}
 			if e.NewState == gol.Quitting {
 				break
 			}
+		case gol.RuleChange:
+			overlay.rule = e.Rule
 		}
 	}
 }
@@ -0,0 +1,95 @@
+package sdl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Colour is a colour in 8-bit red, green and blue.
+type Colour struct {
+	R, G, B byte
+}
+
+// ParseColour reads a colour written in hex as #rrggbb or #rgb.
+func ParseColour(s string) (Colour, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return Colour{}, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+	return Colour{byte(v >> 16), byte(v >> 8), byte(v)}, nil
+}
+
+func (c Colour) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// lerpColour interpolates from a to b as step goes from 0 to steps.
+func lerpColour(a, b Colour, step, steps int) (byte, byte, byte) {
+	return lerp(a.R, b.R, step, steps), lerp(a.G, b.G, step, steps), lerp(a.B, b.B, step, steps)
+}
+
+// Palette is the set of colours cells and gridlines are drawn with.
+// Dying is the colour of cells that died recently, which ColourChanges fades into Background.
+type Palette struct {
+	Background Colour
+	Alive      Colour
+	Dying      Colour
+	Grid       Colour
+}
+
+// DefaultPalette returns the palette used unless it is overridden.
+func DefaultPalette() Palette {
+	return Palette{
+		Background: Colour{0x00, 0x00, 0x00},
+		Alive:      Colour{0xFF, 0xFF, 0xFF},
+		Dying:      Colour{0xFF, 0x00, 0x00},
+		Grid:       Colour{0x30, 0x30, 0x30},
+	}
+}
+
+// Set changes one colour of the palette, named background, alive, dying or grid.
+func (p *Palette) Set(name, value string) error {
+	colour, err := ParseColour(value)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "background":
+		p.Background = colour
+	case "alive":
+		p.Alive = colour
+	case "dying":
+		p.Dying = colour
+	case "grid":
+		p.Grid = colour
+	default:
+		return fmt.Errorf("unknown palette colour %q", name)
+	}
+	return nil
+}
+
+// LoadPalette starts from the default palette, then applies the theme file p.ThemeFile and finally
+// p.Palette. Both hold name=#rrggbb pairs, separated by commas or newlines.
+func LoadPalette(p gol.Params) (Palette, error) {
+	palette := DefaultPalette()
+	if p.ThemeFile != "" {
+		file, err := os.Open(p.ThemeFile)
+		if err != nil {
+			return palette, err
+		}
+		defer file.Close()
+		if err := parseAssignments(file, "colour", palette.Set); err != nil {
+			return palette, fmt.Errorf("%v: %w", p.ThemeFile, err)
+		}
+	}
+	err := parseAssignments(strings.NewReader(p.Palette), "colour", palette.Set)
+	return palette, err
+}
@@ -0,0 +1,29 @@
+package sdl
+
+// PanelWidth is the width of the population panel shown beside the board with the g key, in pixels.
+const PanelWidth = 256
+
+// Population returns the population plotted in the canvas's panel.
+func (c *Canvas) Population() *Population {
+	return &c.population
+}
+
+// PanelVisible reports whether the population panel is shown beside the board.
+func (c *Canvas) PanelVisible() bool {
+	return c.panelVisible
+}
+
+// SetPanel shows or hides the population panel.
+func (c *Canvas) SetPanel(visible bool) {
+	c.panelVisible = visible
+}
+
+// Panel returns the ARGB8888 pixels of the population panel at the given size, with the most recent
+// samples plotted one per column. The slice is reused, so it is only valid until Panel is next called.
+func (c *Canvas) Panel(width, height int) []byte {
+	if len(c.panel) != width*height*4 {
+		c.panel = make([]byte, width*height*4)
+	}
+	plotPopulation(c.panel, width, height, c.population.Recent(width))
+	return c.panel
+}
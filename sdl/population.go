@@ -0,0 +1,145 @@
+package sdl
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// PopulationSample is the number of alive cells after a number of completed turns.
+type PopulationSample struct {
+	CompletedTurns int
+	AliveCells     int
+}
+
+// PopulationHistory is the number of samples a Population keeps by default, which bounds its memory
+// on long runs of small boards. Older samples are discarded.
+const PopulationHistory = 100000
+
+// Population records the number of alive cells over the most recent turns, one sample per completed turn.
+// The zero value keeps the last PopulationHistory samples.
+type Population struct {
+	limit int
+	// samples is a ring buffer whose oldest sample is at start.
+	samples []PopulationSample
+	start   int
+}
+
+// NewPopulation returns a population that keeps the last limit samples.
+func NewPopulation(limit int) *Population {
+	return &Population{limit: limit}
+}
+
+// Record adds a sample, discarding the oldest if the population is full. Samples must arrive in order
+// of completed turns; a second sample for the latest turn replaces the first, and samples for earlier
+// turns are ignored.
+func (pop *Population) Record(completedTurns, aliveCells int) {
+	sample := PopulationSample{completedTurns, aliveCells}
+	if len(pop.samples) > 0 {
+		last := (pop.start + len(pop.samples) - 1) % len(pop.samples)
+		switch {
+		case completedTurns == pop.samples[last].CompletedTurns:
+			pop.samples[last] = sample
+			return
+		case completedTurns < pop.samples[last].CompletedTurns:
+			return
+		}
+	}
+	limit := pop.limit
+	if limit < 1 {
+		limit = PopulationHistory
+	}
+	if len(pop.samples) < limit {
+		pop.samples = append(pop.samples, sample)
+		return
+	}
+	pop.samples[pop.start] = sample
+	pop.start = (pop.start + 1) % len(pop.samples)
+}
+
+// Recent returns up to the last n samples, oldest first.
+func (pop *Population) Recent(n int) []PopulationSample {
+	if n > len(pop.samples) {
+		n = len(pop.samples)
+	}
+	if n < 0 {
+		n = 0
+	}
+	recent := make([]PopulationSample, n)
+	for i := range recent {
+		recent[i] = pop.samples[(pop.start+len(pop.samples)-n+i)%len(pop.samples)]
+	}
+	return recent
+}
+
+// WriteCSV writes the samples kept in the completed_turns,alive_cells format used by check/alive.
+func (pop *Population) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"completed_turns", "alive_cells"})
+	if err != nil {
+		return err
+	}
+	for _, sample := range pop.Recent(len(pop.samples)) {
+		err = writer.Write([]string{strconv.Itoa(sample.CompletedTurns), strconv.Itoa(sample.AliveCells)})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// plotPopulation draws a rolling line chart of the samples into an ARGB8888 pixel buffer,
+// one sample per column, scaled so that the largest population reaches the top.
+func plotPopulation(pixels []byte, width, height int, samples []PopulationSample) {
+	for i := 0; i < len(pixels); i += 4 {
+		pixels[i+0] = 0x20
+		pixels[i+1] = 0x18
+		pixels[i+2] = 0x18
+		pixels[i+3] = 0xFF
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	maxAlive := 1
+	for _, sample := range samples {
+		if sample.AliveCells > maxAlive {
+			maxAlive = sample.AliveCells
+		}
+	}
+
+	top := glyphHeight + 4
+	plotHeight := height - top - 1
+	previous := -1
+	for x, sample := range samples {
+		y := top + plotHeight - sample.AliveCells*plotHeight/maxAlive
+		// Join consecutive samples with a vertical line so steep changes stay visible.
+		from, to := y, y
+		if previous >= 0 {
+			if previous < from {
+				from = previous
+			}
+			if previous > to {
+				to = previous
+			}
+		}
+		for py := from; py <= to; py++ {
+			if py < 0 || py >= height {
+				continue
+			}
+			i := 4 * (py*width + x)
+			pixels[i+0] = 0x40
+			pixels[i+1] = 0xFF
+			pixels[i+2] = 0x40
+		}
+		previous = y
+	}
+
+	last := samples[len(samples)-1]
+	label := "Turn " + strconv.Itoa(last.CompletedTurns) + " Alive " + strconv.Itoa(last.AliveCells) + " Max " + strconv.Itoa(maxAlive)
+	drawText(pixels, width, height, 1, 2, 2, label, 0xFF, 0xFF, 0xFF)
+}
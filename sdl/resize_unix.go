@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package sdl
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize sends on resized whenever the terminal is resized.
+func notifyResize(resized chan<- os.Signal) {
+	signal.Notify(resized, syscall.SIGWINCH)
+}
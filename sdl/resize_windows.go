@@ -0,0 +1,6 @@
+package sdl
+
+import "os"
+
+// notifyResize does nothing, as Windows consoles don't signal that they were resized.
+func notifyResize(resized chan<- os.Signal) {}
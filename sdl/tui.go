@@ -0,0 +1,202 @@
+package sdl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TuiFPS is how often the terminal is redrawn. Terminals are much slower than an SDL texture.
+const TuiFPS = 10
+
+// RunTui draws the world in the terminal instead of an SDL window, for use over SSH.
+// Keys are read from stdin in raw mode, so p, s, q, k, r and n work as they do in the window.
+func RunTui(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+	restore := rawTerminal()
+	defer restore()
+	go readKeys(keyPresses)
+
+	out := bufio.NewWriter(os.Stdout)
+	// Clear the screen and hide the cursor until the run is over.
+	fmt.Fprint(out, "\x1b[2J\x1b[?25l")
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\r\n")
+		out.Flush()
+	}()
+
+	world := makeTuiWorld(p.ImageHeight, p.ImageWidth)
+	dirty := false
+	refreshTicker := time.NewTicker(time.Second / time.Duration(TuiFPS))
+	defer refreshTicker.Stop()
+	avgTurns := util.NewAvgTurns()
+	overlay := hud{state: gol.Executing}
+	status := ""
+
+	// Starting stty on every redraw is slow, so the size is only queried again when the terminal is resized.
+	cols, rows := terminalSize()
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+tui:
+	for {
+		select {
+		case <-refreshTicker.C:
+			if dirty {
+				drawTui(out, p, world, cols, rows, overlay, status)
+				dirty = false
+			}
+
+		case <-resized:
+			cols, rows = terminalSize()
+			dirty = true
+
+		case event, ok := <-events:
+			if !ok {
+				break tui
+			}
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				overlay.alive += flipTuiCell(world, e.Cell)
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					overlay.alive += flipTuiCell(world, cell)
+				}
+				dirty = true
+			case gol.TurnComplete:
+				overlay.turns = e.CompletedTurns
+				dirty = true
+			case gol.AliveCellsCount:
+				overlay.turnsPerSec = avgTurns.Get(event.GetCompletedTurns())
+				dirty = true
+			case gol.ImageOutputComplete, gol.ErrorEvent:
+				status = event.String()
+				dirty = true
+			case gol.StateChange:
+				overlay.state = e.NewState
+				dirty = true
+				if e.NewState == gol.Quitting {
+					break tui
+				}
+			case gol.RuleChange:
+				overlay.rule = e.Rule
+				dirty = true
+			}
+		}
+	}
+	drawTui(out, p, world, cols, rows, overlay, status)
+}
+
+func makeTuiWorld(height, width int) [][]uint8 {
+	world := make([][]uint8, height)
+	for i := range world {
+		world[i] = make([]uint8, width)
+	}
+	return world
+}
+
+// flipTuiCell flips a cell and returns the change in the number of alive cells.
+func flipTuiCell(world [][]uint8, cell util.Cell) int {
+	world[cell.Y][cell.X] ^= 0xFF
+	if world[cell.Y][cell.X] != 0 {
+		return 1
+	}
+	return -1
+}
+
+// drawTui redraws the whole screen: the world, downsampled to fit cols x rows, followed by two status lines.
+func drawTui(out *bufio.Writer, p gol.Params, world [][]uint8, cols, rows int, overlay hud, status string) {
+	lines := util.WorldToTerminal(world, p.ImageWidth, p.ImageHeight, cols, rows-2)
+	// In raw mode a newline does not return the cursor, so every line ends with \r\n.
+	fmt.Fprint(out, "\x1b[H")
+	for _, line := range lines {
+		fmt.Fprintf(out, "%v\x1b[K\r\n", line)
+	}
+	fmt.Fprintf(out, "%v\x1b[K\r\n", strings.Join(overlay.lines(), "  "))
+	fmt.Fprintf(out, "%v\x1b[K\x1b[J", status)
+	out.Flush()
+}
+
+// readKeys forwards key presses from stdin. Ctrl-C quits, since raw mode stops it raising SIGINT,
+// and so does Escape, but escape sequences sent by keys such as the arrows are ignored.
+func readKeys(keyPresses chan<- rune) {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		key, _, err := in.ReadRune()
+		if err != nil {
+			return
+		}
+		switch key {
+		case 'p', 's', 'q', 'k', 'r', 'n':
+			keyPresses <- key
+		case 0x03:
+			keyPresses <- 'q'
+		case 0x1b:
+			// A sequence arrives all at once, while Escape on its own is followed by nothing.
+			if in.Buffered() == 0 {
+				keyPresses <- 'q'
+			} else {
+				skipEscapeSequence(in)
+			}
+		}
+	}
+}
+
+// skipEscapeSequence reads the rest of an escape sequence, such as ESC [ A for the up arrow.
+// CSI (ESC [) and SS3 (ESC O) sequences run until a final byte from @ to ~.
+func skipEscapeSequence(in *bufio.Reader) {
+	introducer, err := in.ReadByte()
+	if err != nil || (introducer != '[' && introducer != 'O') {
+		return
+	}
+	for in.Buffered() > 0 {
+		b, err := in.ReadByte()
+		if err != nil || (b >= '@' && b <= '~') {
+			return
+		}
+	}
+}
+
+// rawTerminal switches stdin to raw mode with stty and returns a function that restores it.
+// If stdin is not a terminal, keys are still read, but only once a line has been entered.
+func rawTerminal() func() {
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return func() {}
+	}
+	return func() {
+		_, _ = stty(strings.TrimSpace(saved))
+	}
+}
+
+// terminalSize returns the number of columns and rows of the terminal, defaulting to 80x24
+// when it can't be queried or has no size set, as with many ptys in containers.
+func terminalSize() (int, int) {
+	size, err := stty("size")
+	if err != nil {
+		return 80, 24
+	}
+	var cols, rows int
+	_, _ = fmt.Sscan(size, &rows, &cols)
+	if cols < 1 || rows < 1 {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
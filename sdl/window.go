@@ -1,9 +1,9 @@
 package sdl
 
 import (
-	"fmt"
+	"image"
 	"unsafe"
-	
+
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -13,7 +13,14 @@ type Window struct {
 	window        *sdl.Window
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
-	pixels        []byte
+	zoom          int32
+	gridZoom      int32
+	// view is the part of the board shown, magnify times larger than the whole board would be.
+	view    sdl.Rect
+	magnify int32
+	// panel holds the population panel, drawn to the right of the board. It is created when first shown.
+	panel *sdl.Texture
+	*Canvas
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
@@ -27,7 +34,8 @@ func NewWindow(width, height int32) *Window {
 	util.Check(err)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
-	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
+	// Nearest-neighbour scaling keeps cells sharp when they are zoomed.
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "nearest")
 	err = renderer.SetLogicalSize(width, height)
 	util.Check(err)
 	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
@@ -40,11 +48,55 @@ func NewWindow(width, height int32) *Window {
 		window,
 		renderer,
 		texture,
-		make([]byte, width*height*4),
+		1,
+		0,
+		sdl.Rect{X: 0, Y: 0, W: width, H: height},
+		1,
+		nil,
+		NewCanvas(int(width), int(height)),
+	}
+}
+
+// SetZoom resizes the window so that every cell is zoom pixels wide.
+// Gridlines are drawn between cells while zoom is at least gridZoom, unless gridZoom is 0.
+func (w *Window) SetZoom(zoom, gridZoom int) {
+	if zoom < 1 {
+		zoom = 1
+	}
+	w.zoom = int32(zoom)
+	w.gridZoom = int32(gridZoom)
+	w.layout()
+}
+
+// SetPanel shows or hides the population panel, widening the window to make room for it.
+func (w *Window) SetPanel(visible bool) {
+	w.Canvas.SetPanel(visible)
+	w.layout()
+}
+
+// layout sizes the window to fit the board, and the population panel beside it while it is shown.
+func (w *Window) layout() {
+	width, height := w.Width*w.zoom, w.Height*w.zoom
+	if w.PanelVisible() {
+		width += PanelWidth
+	}
+	w.window.SetSize(width, height)
+	err := w.renderer.SetLogicalSize(width, height)
+	util.Check(err)
+	// The panel is as tall as the board, so it is recreated at the new size when next drawn.
+	w.destroyPanel()
+}
+
+func (w *Window) destroyPanel() {
+	if w.panel != nil {
+		err := w.panel.Destroy()
+		util.Check(err)
+		w.panel = nil
 	}
 }
 
 func (w *Window) Destroy() {
+	w.destroyPanel()
 	err := w.texture.Destroy()
 	util.Check(err)
 	err = w.renderer.Destroy()
@@ -55,51 +107,111 @@ func (w *Window) Destroy() {
 }
 
 func (w *Window) RenderFrame() {
-	err := w.texture.Update(nil, unsafe.Pointer(&w.pixels[0]), int(w.Width*4))
+	w.render(w.Frame())
+}
+
+// render draws ARGB8888 pixels the size of the window.
+func (w *Window) render(pixels []byte) {
+	err := w.texture.Update(nil, unsafe.Pointer(&pixels[0]), int(w.Width*4))
 	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
-	err = w.renderer.Copy(w.texture, nil, nil)
+	board := sdl.Rect{X: 0, Y: 0, W: w.Width * w.zoom, H: w.Height * w.zoom}
+	err = w.renderer.Copy(w.texture, &w.view, &board)
 	util.Check(err)
+	if w.gridZoom > 0 && w.zoom*w.magnify >= w.gridZoom {
+		w.drawGrid()
+	}
+	if w.PanelVisible() {
+		w.renderPanel(board.H)
+	}
 	w.renderer.Present()
 }
 
-func (w *Window) PollEvent() sdl.Event {
-	return sdl.PollEvent()
+// renderPanel draws the population panel to the right of the board, height pixels tall.
+func (w *Window) renderPanel(height int32) {
+	var err error
+	if w.panel == nil {
+		w.panel, err = w.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, PanelWidth, height)
+		util.Check(err)
+	}
+	pixels := w.Panel(PanelWidth, int(height))
+	err = w.panel.Update(nil, unsafe.Pointer(&pixels[0]), PanelWidth*4)
+	util.Check(err)
+	err = w.renderer.Copy(w.panel, nil, &sdl.Rect{X: w.Width * w.zoom, Y: 0, W: PanelWidth, H: height})
+	util.Check(err)
+}
+
+// drawGrid draws a line between every row and column of cells.
+func (w *Window) drawGrid() {
+	grid := w.Palette().Grid
+	err := w.renderer.SetDrawColor(grid.R, grid.G, grid.B, 0xFF)
+	util.Check(err)
+	width, height := w.Width*w.zoom, w.Height*w.zoom
+	cell := w.zoom * w.magnify
+	for x := cell; x < width; x += cell {
+		err = w.renderer.DrawLine(x, 0, x, height-1)
+		util.Check(err)
+	}
+	for y := cell; y < height; y += cell {
+		err = w.renderer.DrawLine(0, y, width-1, y)
+		util.Check(err)
+	}
+}
+
+// View returns the cells shown in the window.
+func (w *Window) View() image.Rectangle {
+	return image.Rect(int(w.view.X), int(w.view.Y), int(w.view.X+w.view.W), int(w.view.Y+w.view.H))
 }
 
-func (w *Window) SetPixel(x, y int) {
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = 0xFF
-	w.pixels[4*(y*width+x)+1] = 0xFF
-	w.pixels[4*(y*width+x)+2] = 0xFF
-	w.pixels[4*(y*width+x)+3] = 0xFF
+// Zoomed reports whether only part of the board is shown.
+func (w *Window) Zoomed() bool {
+	return w.magnify > 1
 }
 
-func (w *Window) FlipPixel(x, y int) {
-	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
-		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
+// Magnify doubles the size of cells when in is true and halves it otherwise, keeping the centre of the view.
+// Cells are never shown smaller than they are without magnification, or so large that a row doesn't fit.
+func (w *Window) Magnify(in bool) {
+	magnify := w.magnify
+	if in && w.Width/(magnify*2) >= 1 && w.Height/(magnify*2) >= 1 {
+		magnify *= 2
+	} else if !in && magnify > 1 {
+		magnify /= 2
 	}
+	centreX, centreY := w.view.X+w.view.W/2, w.view.Y+w.view.H/2
+	w.magnify = magnify
+	w.view.W, w.view.H = w.Width/magnify, w.Height/magnify
+	w.moveView(centreX-w.view.W/2, centreY-w.view.H/2)
+}
 
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
-	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
-	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
-	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
+// Pan moves the view by a quarter of its size in each direction, e.g. dx = -1 to pan left.
+func (w *Window) Pan(dx, dy int) {
+	w.moveView(w.view.X+int32(dx)*max32(w.view.W/4, 1), w.view.Y+int32(dy)*max32(w.view.H/4, 1))
 }
 
-func (w *Window) CountPixels() int {
-	count := 0
-	for i := 0; i < int(w.Width) * int(w.Height) * 4; i += 4 {
-		if w.pixels[i] == 0xFF {
-			count++
-		}
+// moveView places the top left corner of the view, keeping it within the board.
+func (w *Window) moveView(x, y int32) {
+	w.view.X = clamp32(x, 0, w.Width-w.view.W)
+	w.view.Y = clamp32(y, 0, w.Height-w.view.H)
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
 	}
-	return count
+	return b
 }
 
-func (w *Window) ClearPixels() {
-	for i := range w.pixels {
-		w.pixels[i] = 0
+func clamp32(v, low, high int32) int32 {
+	if v < low {
+		return low
+	}
+	if v > high {
+		return high
 	}
+	return v
+}
+
+func (w *Window) PollEvent() sdl.Event {
+	return sdl.PollEvent()
 }
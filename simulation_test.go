@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSimulation tests that a Simulation stepped synchronously reaches the expected worlds,
+// and that snapshots and returned worlds are unaffected by later changes.
+func TestSimulation(t *testing.T) {
+	p := gol.Params{Threads: 8, ImageWidth: 16, ImageHeight: 16}
+	initial := readAliveCells("images/16x16.pgm", 16, 16)
+	sim, err := gol.NewSimulation(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range initial {
+		sim.SetCell(cell.X, cell.Y, true)
+	}
+	world := sim.World()
+	snapshot := sim.Snapshot()
+
+	sim.Step(1)
+	assert(t, sim.Turn() == 1, "The simulation should be at turn 1, not %v\n", sim.Turn())
+	assertEqualBoard(t, sim.Alive(), readAliveCells("check/images/16x16x1.pgm", 16, 16), p)
+	sim.Step(99)
+	assert(t, sim.Turn() == 100, "The simulation should be at turn 100, not %v\n", sim.Turn())
+	assertEqualBoard(t, sim.Alive(), readAliveCells("check/images/16x16x100.pgm", 16, 16), p)
+
+	assert(t, snapshot.Turn() == 0, "Stepping should not change a snapshot's turn\n")
+	assertEqualBoard(t, snapshot.Alive(), initial, p)
+	snapshot.SetCell(0, 0, world[0][0] == 0)
+	assert(t, snapshot.World()[0][0] != world[0][0], "SetCell should change the snapshot's world\n")
+	assertEqualBoard(t, sim.Alive(), readAliveCells("check/images/16x16x100.pgm", 16, 16), p)
+	alive := 0
+	for _, row := range world {
+		for _, cell := range row {
+			if cell != 0 {
+				alive++
+			}
+		}
+	}
+	assert(t, alive == len(initial), "A world returned by World should not change, expected %v alive cells, got %v\n", len(initial), alive)
+
+	snapshot.Clear()
+	assert(t, len(snapshot.Alive()) == 0, "Clear should kill every cell\n")
+	assert(t, len(sim.Alive()) > 0, "Clearing a snapshot should not clear the simulation it was taken from\n")
+
+	p.Threads = 1
+	single, err := gol.NewSimulation(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range initial {
+		single.SetCell(cell.X, cell.Y, true)
+	}
+	single.Step(100)
+	assertEqualBoard(t, single.Alive(), sim.Alive(), p)
+}
+
+// TestSimulationRule tests that a Simulation follows p.Rule, and that one that isn't in B/S notation is rejected.
+func TestSimulationRule(t *testing.T) {
+	p := gol.Params{Threads: 4, ImageWidth: 16, ImageHeight: 16, Rule: "B36/S23"}
+	sim, err := gol.NewSimulation(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, sim.Rule() == "B36/S23", "The simulation should follow p.Rule, not %v\n", sim.Rule())
+	// Under HighLife, but not the Game of Life, a dead cell with 6 alive neighbours comes alive.
+	for _, cell := range [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 2}, {1, 2}, {2, 2}} {
+		sim.SetCell(cell[0], cell[1], true)
+	}
+	sim.Step(1)
+	assert(t, sim.World()[1][1] != 0, "A dead cell with 6 alive neighbours should come alive under B36/S23\n")
+
+	p.Rule = "B3/X23"
+	_, err = gol.NewSimulation(p)
+	assert(t, err != nil, "A rule that isn't in B/S notation should be rejected\n")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSnapshots tests that several snapshots requested in quick succession are all written correctly
+// while the simulation keeps executing.
+func TestSnapshots(t *testing.T) {
+	p := gol.Params{
+		Turns:       100000000,
+		Threads:     8,
+		ImageWidth:  512,
+		ImageHeight: 512,
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+
+	emptyOutFolder()
+
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	go gol.Run(p, events, keyPresses)
+
+	var outputs []gol.ImageOutputComplete
+	timer := time.After(500 * time.Millisecond)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.ImageOutputComplete:
+			outputs = append(outputs, e)
+		case gol.ErrorEvent:
+			t.Fatalf("ERROR: Unexpected error %v", e.Err)
+		}
+		select {
+		case <-timer:
+			keyPresses <- 's'
+			keyPresses <- 's'
+			keyPresses <- 's'
+			keyPresses <- 'q'
+		default:
+		}
+	}
+
+	// Three snapshots and the final image.
+	if len(outputs) != 4 {
+		t.Fatalf("ERROR: Expected 4 ImageOutputComplete events, got %v instead", len(outputs))
+	}
+	for _, e := range outputs {
+		cells := readAliveCells(fmt.Sprintf("out/%v.pgm", e.Filename), p.ImageWidth, p.ImageHeight)
+		expected := 5565
+		if e.CompletedTurns <= 10000 {
+			expected = alive[e.CompletedTurns]
+		} else if e.CompletedTurns%2 == 1 {
+			expected = 5567
+		}
+		assert(t, len(cells) == expected, "At turn %v expected %v alive cells in output PGM image, got %v instead", e.CompletedTurns, expected, len(cells))
+	}
+}
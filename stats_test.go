@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestTurnStats tests that TurnStats events describe the world rebuilt from the flipped cells.
+func TestTurnStats(t *testing.T) {
+	for _, interval := range []int{1, 3} {
+		params := gol.Params{Turns: 20, Threads: 4, ImageWidth: 16, ImageHeight: 16, OutputDir: t.TempDir(), StatsInterval: interval}
+		events := make(chan gol.Event, 1000)
+		go gol.Run(params, events, make(chan rune, 10))
+
+		world := make(map[util.Cell]bool)
+		births, deaths := 0, 0
+		var reported []int
+		timeout(t, 5*time.Second, func() {
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellsFlipped:
+					for _, cell := range e.Cells {
+						world[cell] = !world[cell]
+						if e.CompletedTurns == 0 {
+							continue
+						}
+						if world[cell] {
+							births++
+						} else {
+							deaths++
+						}
+					}
+				case gol.TurnStats:
+					reported = append(reported, e.CompletedTurns)
+					checkTurnStats(t, e, world, births, deaths)
+					births, deaths = 0, 0
+				}
+			}
+		}, "The run should finish")
+
+		expected := 0
+		for _, turn := range reported {
+			assert(t, turn == expected, "With an interval of %v, TurnStats should be sent for turn %v, not %v\n", interval, expected, turn)
+			expected += interval
+		}
+		assert(t, expected > 20, "With an interval of %v, TurnStats should be sent up to turn 20, not %v\n", interval, expected-interval)
+	}
+}
+
+// checkTurnStats compares stats with the alive cells in world.
+func checkTurnStats(t *testing.T, stats gol.TurnStats, world map[util.Cell]bool, births, deaths int) {
+	alive := 0
+	bounds := image.Rectangle{}
+	sumX, sumY := 0, 0
+	for cell, isAlive := range world {
+		if !isAlive {
+			continue
+		}
+		alive++
+		sumX += cell.X
+		sumY += cell.Y
+		bounds = bounds.Union(image.Rect(cell.X, cell.Y, cell.X+1, cell.Y+1))
+	}
+	turn := stats.CompletedTurns
+	assert(t, stats.Births == births && stats.Deaths == deaths, "Turn %v should have %v births and %v deaths, not %v and %v\n", turn, births, deaths, stats.Births, stats.Deaths)
+	assert(t, stats.Alive == alive, "Turn %v should have %v alive cells, not %v\n", turn, alive, stats.Alive)
+	assert(t, stats.Bounds == bounds, "Turn %v should have bounds %v, not %v\n", turn, bounds, stats.Bounds)
+	if alive > 0 {
+		x, y := float64(sumX)/float64(alive), float64(sumY)/float64(alive)
+		assert(t, stats.CentroidX == x && stats.CentroidY == y, "Turn %v should have its centroid at (%v, %v), not (%v, %v)\n", turn, x, y, stats.CentroidX, stats.CentroidY)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestTui tests that worlds are drawn in the terminal with half blocks, or downsampled braille if they don't fit.
+func TestTui(t *testing.T) {
+	world := [][]uint8{
+		{255, 0, 255, 0},
+		{255, 0, 0, 255},
+		{0, 0, 0, 0},
+	}
+
+	lines := util.WorldToTerminal(world, 4, 3, 80, 24)
+	expected := []string{"█ ▀▄", "    "}
+	assert(t, len(lines) == len(expected), "Expected %v half-block lines, got %v\n", len(expected), len(lines))
+	for i := range expected {
+		assert(t, lines[i] == expected[i], "Half-block line %v should be %q, not %q\n", i, expected[i], lines[i])
+	}
+
+	big := make([][]uint8, 512)
+	for y := range big {
+		big[y] = make([]uint8, 512)
+	}
+	big[0][0] = 255
+	big[511][511] = 255
+	lines = util.WorldToTerminal(big, 512, 512, 80, 22)
+	assert(t, len(lines) <= 22, "Braille output should fit in 22 rows, got %v\n", len(lines))
+	for _, line := range lines {
+		assert(t, utf8.RuneCountInString(line) <= 80, "Braille output should fit in 80 columns, got %v\n", utf8.RuneCountInString(line))
+	}
+	first, _ := utf8.DecodeRuneInString(lines[0])
+	assert(t, first == '⠁', "Top left braille character should be ⠁, not %q\n", first)
+
+	// Terminals with no size set report 0 0, which leaves no rows for the world.
+	lines = util.WorldToTerminal(big, 512, 512, 0, -2)
+	assert(t, len(lines) == 1, "A world drawn in no space should be squashed into 1 line, got %v\n", len(lines))
+	assert(t, utf8.RuneCountInString(lines[0]) == 1, "A world drawn in no space should be squashed into 1 column, got %v\n", utf8.RuneCountInString(lines[0]))
+}
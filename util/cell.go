@@ -2,5 +2,6 @@ package util
 
 // Cell is used as the return type for the testing framework.
 type Cell struct {
-	X, Y int
+	X int `json:"x"`
+	Y int `json:"y"`
 }
\ No newline at end of file
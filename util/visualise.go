@@ -106,3 +106,81 @@ func squaresToStrings(given, expected [][]uint8, width, height int) []string {
 
 	return output
 }
+
+// WorldToTerminal renders a world as lines of Unicode text that fit within cols x rows characters.
+// Worlds that fit are drawn with half blocks, two cells per character. Larger worlds are drawn with
+// braille, 2x4 dots per character, downsampling square blocks of cells into a dot that is set if
+// any cell in the block is alive. Terminals reported as smaller than a character are treated as one.
+func WorldToTerminal(world [][]uint8, width, height, cols, rows int) []string {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	if width <= cols && (height+1)/2 <= rows {
+		return halfBlocks(world, width, height)
+	}
+	scale := 1
+	for (width+2*scale-1)/(2*scale) > cols || (height+4*scale-1)/(4*scale) > rows {
+		scale++
+	}
+	return braille(world, width, height, scale)
+}
+
+func halfBlocks(world [][]uint8, width, height int) []string {
+	var lines []string
+	for y := 0; y < height; y += 2 {
+		var line strings.Builder
+		for x := 0; x < width; x++ {
+			top := world[y][x] != 0
+			bottom := y+1 < height && world[y+1][x] != 0
+			switch {
+			case top && bottom:
+				line.WriteRune('█')
+			case top:
+				line.WriteRune('▀')
+			case bottom:
+				line.WriteRune('▄')
+			default:
+				line.WriteRune(' ')
+			}
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// brailleDots maps a dot position within a 2x4 braille character to its bit in the code point.
+var brailleDots = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+func braille(world [][]uint8, width, height, scale int) []string {
+	anyAlive := func(x0, y0 int) bool {
+		for y := y0; y < y0+scale && y < height; y++ {
+			for x := x0; x < x0+scale && x < width; x++ {
+				if world[y][x] != 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var lines []string
+	for y := 0; y < height; y += 4 * scale {
+		var line strings.Builder
+		for x := 0; x < width; x += 2 * scale {
+			char := rune(0x2800)
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					if anyAlive(x+dx*scale, y+dy*scale) {
+						char |= brailleDots[dy][dx]
+					}
+				}
+			}
+			line.WriteRune(char)
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
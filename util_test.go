@@ -307,6 +307,10 @@ func (tester *Tester) TestAlive() {
 	}
 	assert(tester.t, aliveCount == expected,
 		"At turn %v expected %v alive cells in the SDL window, got %v instead", tester.turn, expected, aliveCount)
+
+	displayed := displayedCount()
+	assert(tester.t, displayed == expected,
+		"At turn %v expected the display to count %v alive cells, got %v instead", tester.turn, expected, displayed)
 }
 
 func (tester *Tester) TestImage() {
@@ -335,6 +339,9 @@ func (tester *Tester) TestImage() {
 				tester.t.Errorf("ERROR: The image displayed in the SDL window is incorrect for turn %v", tester.turn)
 			}
 		}
+		if !checkEqualBoard(displayedCells(), expectedAlive) {
+			tester.t.Errorf("ERROR: The pixels drawn on the display are incorrect for turn %v", tester.turn)
+		}
 	} else {
 		fmt.Printf("WARNING: TestImage called on invalid turn: %v. This call will be ignored\n", tester.turn)
 	}
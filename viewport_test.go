@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestViewport tests that only cells inside the viewport are flipped, and that moving it while paused
+// brings the newly watched cells up to date.
+func TestViewport(t *testing.T) {
+	first := image.Rect(8, 8, 40, 24)
+	second := image.Rect(20, 30, 64, 64)
+	viewport := gol.NewViewport()
+	viewport.Watch(first)
+	params := gol.Params{Turns: 100000, Threads: 8, ImageWidth: 64, ImageHeight: 64, OutputDir: t.TempDir()}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	go gol.Run(params, events, keyPresses, gol.WithViewport(viewport))
+
+	world := make(map[util.Cell]bool)
+	watched := first
+	flip := func(cells []util.Cell) {
+		for _, cell := range cells {
+			assert(t, image.Pt(cell.X, cell.Y).In(watched), "Cell (%v, %v) is outside the viewport %v\n", cell.X, cell.Y, watched)
+			world[cell] = !world[cell]
+		}
+	}
+
+	var final []util.Cell
+	paused := -1
+	moved := false
+	timeout(t, 10*time.Second, func() {
+		for event := range events {
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				flip([]util.Cell{e.Cell})
+			case gol.CellsFlipped:
+				if paused >= 0 {
+					assert(t, e.CompletedTurns == paused, "Flips sent while paused should be for turn %v, not %v\n", paused, e.CompletedTurns)
+					moved = true
+				}
+				flip(e.Cells)
+			case gol.TurnComplete:
+				if e.CompletedTurns == 10 {
+					keyPresses <- 'p'
+				}
+			case gol.StateChange:
+				if e.NewState == gol.Paused {
+					paused = e.CompletedTurns
+					// The union is watched until the old cells are flipped, which don't change while paused.
+					watched = first.Union(second)
+					viewport.Watch(second)
+					go func() {
+						time.Sleep(100 * time.Millisecond)
+						keyPresses <- 'p'
+					}()
+				}
+				if e.NewState == gol.Executing && paused >= 0 {
+					paused = -1
+					watched = second
+					keyPresses <- 'q'
+				}
+			case gol.FinalTurnComplete:
+				final = e.Alive
+			}
+		}
+	}, "The run should quit after being paused")
+
+	assert(t, moved, "Moving the viewport while paused should flip the newly watched cells\n")
+	expected := make(map[util.Cell]bool)
+	for _, cell := range final {
+		if image.Pt(cell.X, cell.Y).In(second) {
+			expected[cell] = true
+		}
+	}
+	for y := second.Min.Y; y < second.Max.Y; y++ {
+		for x := second.Min.X; x < second.Max.X; x++ {
+			cell := util.Cell{X: x, Y: y}
+			assert(t, world[cell] == expected[cell], "Cell (%v, %v) should be %v in the viewport\n", x, y, expected[cell])
+		}
+	}
+}